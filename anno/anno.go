@@ -1,85 +1,438 @@
 package anno
 
 import (
+	"errors"
+	"fmt"
 	"open-anno/anno/db"
 	"open-anno/anno/gene"
 	"open-anno/pkg"
+	"path"
+	"strings"
 	"sync"
 
 	"github.com/brentp/bix"
 	"github.com/brentp/faidx"
+	"github.com/brentp/vcfgo"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
-type AnnoInfo struct {
-	PK    string
-	Data  map[string]any
-	Error error
+// Options 注释器参数
+type Options struct {
+	gene.Options
+	GenePred            string                 // GenePred文件，需有tabix索引
+	Genome              string                 // 参考基因组Fasta文件，需有fai索引，仅注释CNV时可为空
+	FilterBaseds        []string               // FilterBased数据库VCF文件
+	FilterBasedLevelDBs []string               // FilterBased数据库LevelDB目录，由pre leveldb生成
+	RegionBaseds        []string               // RegionBased数据库BED文件
+	GeneBaseds          []string               // GeneBased数据库TSV文件
+	PositionBaseds      []db.PositionBasedConf // PositionBased打分数据库，bigWig或bedGraph
+	Overlap             db.Overlap             // RegionBased数据库默认的重叠方式
+	Overlaps            map[string]db.Overlap  // 各RegionBased数据库的重叠方式，以数据库名称为键
 }
 
-func (this *AnnoInfo) AddAnno(anno map[string]any) {
-	for key, val := range anno {
-		this.Data[key] = val
+// DefaultOptions 默认注释器参数
+func DefaultOptions() Options {
+	return Options{Options: gene.DefaultOptions(), Overlap: db.Overlap{Mode: db.Overlap_QUERY, Fraction: 0.7}}
+}
+
+// DBOverlap RegionBased数据库的重叠方式，未单独指定时使用默认重叠方式
+func (this Options) DBOverlap(dbname string) db.Overlap {
+	if overlap, ok := this.Overlaps[dbname]; ok {
+		return overlap
 	}
+	return this.Overlap
+}
+
+// OverlapKey RegionBased数据库重叠比例的INFO字段名
+func OverlapKey(dbname string) string {
+	return dbname + "_OVERLAP"
+}
+
+// DBName RegionBased及GeneBased数据库名称，为文件名第一个.之前的部分
+func DBName(dbFile string) string {
+	return strings.Split(path.Base(dbFile), ".")[0]
+}
+
+// Annotator 注释器，持有参数及文件句柄，AnnotateSNV/AnnotateCNV可并发调用
+type Annotator struct {
+	Options
+	DBNames  []string
+	Schemas  []db.RegionSchema // 各RegionBased数据库的schema，与DBNames一一对应
+	GeneDBs  []*db.GeneBased
+	ScoreDBs []*db.PositionBased
+	gpeTbx   *bix.Bix
+	genome   *faidx.Faidx
+	fbTbxs   []*bix.Bix
+	fbLDBs   []*leveldb.DB
+	rbTbxs   []*bix.Bix
 }
 
-func AnnoSnv(snv *pkg.SNV, gpeTbx *bix.Bix, fbTbxs []*bix.Bix, rbTbxs []*bix.Bix, dbnames []string, genome *faidx.Faidx, overlap float64) AnnoInfo {
-	annoInfo := AnnoInfo{PK: snv.PK(), Error: nil, Data: make(map[string]any)}
-	var anno map[string]any
+// NewAnnotator 打开GenePred、Genome及数据库文件，创建注释器
+func NewAnnotator(opts Options) (*Annotator, error) {
 	var err error
-	anno, err = gene.AnnoSnv(snv, gpeTbx, genome)
+	annotator := &Annotator{Options: opts}
+	annotator.gpeTbx, err = bix.New(opts.GenePred)
 	if err != nil {
-		annoInfo.Error = err
-		return annoInfo
+		return annotator, err
+	}
+	if opts.Genome != "" {
+		annotator.genome, err = faidx.New(opts.Genome)
+		if err != nil {
+			annotator.Close()
+			return annotator, err
+		}
 	}
-	annoInfo.AddAnno(anno)
-	for _, tbx := range fbTbxs {
-		anno, err = db.AnnoFilterBased(snv, tbx)
+	for _, fbFile := range opts.FilterBaseds {
+		fbTbx, err := bix.New(fbFile)
 		if err != nil {
-			annoInfo.Error = err
-			return annoInfo
+			annotator.Close()
+			return annotator, err
 		}
-		annoInfo.AddAnno(anno)
+		annotator.fbTbxs = append(annotator.fbTbxs, fbTbx)
 	}
-	for i, tbx := range rbTbxs {
-		anno, err = db.AnnoRegionBased(snv, tbx, overlap, dbnames[i])
+	for _, fbDir := range opts.FilterBasedLevelDBs {
+		fbLDB, err := leveldb.OpenFile(fbDir, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
 		if err != nil {
-			annoInfo.Error = err
-			return annoInfo
+			annotator.Close()
+			return annotator, err
 		}
-		annoInfo.AddAnno(anno)
+		annotator.fbLDBs = append(annotator.fbLDBs, fbLDB)
 	}
-	return annoInfo
+	for _, rbFile := range opts.RegionBaseds {
+		rbTbx, err := bix.New(rbFile)
+		if err != nil {
+			annotator.Close()
+			return annotator, err
+		}
+		annotator.rbTbxs = append(annotator.rbTbxs, rbTbx)
+		dbname := DBName(rbFile)
+		schema, err := db.ReadRegionSchema(rbFile, dbname)
+		if err != nil {
+			annotator.Close()
+			return annotator, err
+		}
+		annotator.DBNames = append(annotator.DBNames, dbname)
+		annotator.Schemas = append(annotator.Schemas, schema)
+	}
+	for _, gbFile := range opts.GeneBaseds {
+		geneBased, err := db.ReadGeneBased(gbFile, DBName(gbFile))
+		if err != nil {
+			annotator.Close()
+			return annotator, err
+		}
+		annotator.GeneDBs = append(annotator.GeneDBs, geneBased)
+	}
+	for _, conf := range opts.PositionBaseds {
+		positionBased, err := db.OpenPositionBased(conf)
+		if err != nil {
+			annotator.Close()
+			return annotator, err
+		}
+		annotator.ScoreDBs = append(annotator.ScoreDBs, positionBased)
+	}
+	return annotator, nil
 }
 
-func AnnoSnvWorker(snvs chan *pkg.SNV, gpeTbx *bix.Bix, fbTbxs []*bix.Bix, rbTbxs []*bix.Bix, dbnames []string, genome *faidx.Faidx, overlap float64, result chan AnnoInfo, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for snv := range snvs {
-		result <- AnnoSnv(snv, gpeTbx, fbTbxs, rbTbxs, dbnames, genome, overlap)
+// Close 关闭注释器的文件句柄
+func (this *Annotator) Close() {
+	if this.gpeTbx != nil {
+		this.gpeTbx.Close()
+	}
+	if this.genome != nil {
+		this.genome.Close()
+	}
+	for _, tbx := range this.fbTbxs {
+		tbx.Close()
+	}
+	for _, ldb := range this.fbLDBs {
+		ldb.Close()
+	}
+	for _, tbx := range this.rbTbxs {
+		tbx.Close()
+	}
+	for _, positionBased := range this.ScoreDBs {
+		positionBased.Close()
+	}
+}
+
+// Genome 参考基因组句柄
+func (this *Annotator) Genome() *faidx.Faidx {
+	return this.genome
+}
+
+// Codons SNV在各重叠编码转录本上所在的密码子
+func (this *Annotator) Codons(snv *pkg.SNV) ([]string, error) {
+	return this.Options.Codons(snv, this.gpeTbx)
+}
+
+// LevelDBHeaderInfos FilterBased LevelDB数据库的VcfHeaderInfo
+func (this *Annotator) LevelDBHeaderInfos() ([]*vcfgo.Info, error) {
+	infos := make([]*vcfgo.Info, 0)
+	for _, ldb := range this.fbLDBs {
+		headInfos, err := db.GetHeaderLevelDB(ldb)
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, headInfos...)
+	}
+	return infos, nil
+}
+
+// MNVResult 同一单倍型上相邻SNP合并后的注释结果
+type MNVResult struct {
+	Name string // FORMAT=Chrom:Pos:Ref/Alt
+	SnvResult
+}
+
+// GeneBasedAnno 基因在GeneBased数据库中的注释结果
+type GeneBasedAnno struct {
+	Gene   string
+	GeneID string
+	Values map[string]string // 以INFO字段名为键
+}
+
+// geneBasedInfo 转换为VCF INFO字段，每个基因一个值，以,连接，顺序同GENE，基因不在数据库中时为.
+func geneBasedInfo(annos []GeneBasedAnno) map[string]any {
+	keys := make([]string, 0)
+	for _, anno := range annos {
+		for key := range anno.Values {
+			if pkg.FindArr(keys, key) < 0 {
+				keys = append(keys, key)
+			}
+		}
+	}
+	info := make(map[string]any)
+	for _, key := range keys {
+		values := make([]string, len(annos))
+		for i, anno := range annos {
+			values[i] = "."
+			if val, ok := anno.Values[key]; ok {
+				values[i] = val
+			}
+		}
+		info[key] = strings.Join(values, ",")
+	}
+	return info
+}
+
+// SnvResult SNV注释结果
+type SnvResult struct {
+	PK            string
+	Transcripts   []gene.TransAnno
+	NearestGenes  gene.NearestGenes // 无转录本注释时为基因间区变异两侧最近的基因
+	HGVSg         string
+	FilterBased   map[string]any // FilterBased数据库的INFO字段，值的类型由数据库header的Type/Number决定
+	RegionBased   map[string][]db.RegionRecord
+	RegionInfo    map[string]any     // RegionBased数据库按schema汇总的INFO字段
+	GeneBased     []GeneBasedAnno    // 按GENE顺序的GeneBased数据库注释
+	PositionBased map[string]float64 // PositionBased数据库在变异位置的打分，以INFO字段名为键
+	MNVs          []MNVResult
+	Error         error
+}
+
+// Info 转换为VCF INFO字段，同一基因内以|连接，不同基因间以,连接
+func (this SnvResult) Info() map[string]any {
+	info := gene.GeneInfo(this.Transcripts)
+	if len(this.Transcripts) == 0 {
+		info["REGION"] = "intergenic"
+		info["NEAREST_GENE"] = this.NearestGenes.String()
+	}
+	info["HGVSg"] = this.HGVSg
+	for key, val := range this.FilterBased {
+		info[key] = val
 	}
+	for key, val := range this.PositionBased {
+		info[key] = val
+	}
+	for key, val := range this.RegionInfo {
+		info[key] = val
+	}
+	for dbname, records := range this.RegionBased {
+		info[OverlapKey(dbname)] = db.RegionOverlaps(records)
+	}
+	for key, val := range geneBasedInfo(this.GeneBased) {
+		info[key] = val
+	}
+	for _, mnv := range this.MNVs {
+		mnvInfo := mnv.Info()
+		mnvInfo["MNV"] = mnv.Name
+		for _, key := range []string{"MNV", "DETAIL", "EVENT", "HGVSg", "HGVSc", "HGVSp"} {
+			id := key
+			if key != "MNV" {
+				id = "MNV_" + key
+			}
+			value := "."
+			if val, ok := mnvInfo[key]; ok && val != "" {
+				value = fmt.Sprint(val)
+			}
+			if old, ok := info[id]; ok {
+				value = fmt.Sprintf("%s,%s", old, value)
+			}
+			info[id] = value
+		}
+	}
+	return info
+}
+
+// CnvResult CNV注释结果
+type CnvResult struct {
+	PK          string
+	Transcripts []gene.CnvTransAnno
+	RegionBased map[string][]db.RegionRecord
+	RegionInfo  map[string]any  // RegionBased数据库按schema汇总的INFO字段
+	GeneBased   []GeneBasedAnno // 按GENE顺序的GeneBased数据库注释
+	Error       error
+}
+
+// Info 转换为VCF INFO字段
+func (this CnvResult) Info() map[string]any {
+	info := gene.CnvInfo(this.Transcripts)
+	for key, val := range this.RegionInfo {
+		info[key] = val
+	}
+	for dbname, records := range this.RegionBased {
+		info[OverlapKey(dbname)] = db.RegionOverlaps(records)
+	}
+	for key, val := range geneBasedInfo(this.GeneBased) {
+		info[key] = val
+	}
+	return info
+}
+
+// annoRegionBaseds 注释所有RegionBased数据库，返回重叠的记录及按schema汇总的INFO字段
+func (this *Annotator) annoRegionBaseds(variant pkg.IVariant) (map[string][]db.RegionRecord, map[string]any, error) {
+	result, info := make(map[string][]db.RegionRecord), make(map[string]any)
+	for i, tbx := range this.rbTbxs {
+		records, err := db.AnnoRegionBased(variant, tbx, this.DBOverlap(this.DBNames[i]), this.Schemas[i])
+		if err != nil {
+			return result, info, err
+		}
+		result[this.DBNames[i]] = records
+		for key, val := range this.Schemas[i].Aggregate(records) {
+			info[key] = val
+		}
+	}
+	return result, info, nil
+}
+
+// annoGeneBased 注释基因在所有GeneBased数据库中的值，基因已注释时跳过
+func (this *Annotator) annoGeneBased(annos []GeneBasedAnno, name, geneID string) []GeneBasedAnno {
+	if len(this.GeneDBs) == 0 {
+		return annos
+	}
+	for _, anno := range annos {
+		if anno.Gene == name {
+			return annos
+		}
+	}
+	anno := GeneBasedAnno{Gene: name, GeneID: geneID, Values: make(map[string]string)}
+	for _, geneBased := range this.GeneDBs {
+		values, _ := geneBased.Anno(name, geneID)
+		for key, val := range values {
+			anno.Values[key] = val
+		}
+	}
+	return append(annos, anno)
+}
+
+// AnnotateGene 仅注释SNV的基因功能
+func (this *Annotator) AnnotateGene(snv *pkg.SNV) (SnvResult, error) {
+	var err error
+	result := SnvResult{PK: snv.PK()}
+	if this.genome == nil {
+		return result, errors.New("genome is required to annotate SNV")
+	}
+	result.Transcripts, err = this.AnnoSnvTranses(snv, this.gpeTbx, this.genome)
+	if err != nil {
+		return result, err
+	}
+	annoVar := snv.AnnoVariant()
+	if len(result.Transcripts) == 0 {
+		result.NearestGenes, err = this.AnnoIntergenic(annoVar, this.gpeTbx)
+		if err != nil {
+			return result, err
+		}
+	}
+	result.HGVSg, err = annoVar.HGVSg(this.genome, this.Build)
+	return result, err
 }
 
-func AnnoCnv(cnv *pkg.CNV, gpeTbx *bix.Bix, gbTbxs []*bix.Bix, dbnames []string, overlap float64) AnnoInfo {
-	annoInfo := AnnoInfo{PK: cnv.PK(), Error: nil, Data: make(map[string]any)}
-	anno, err := gene.AnnoCnv(cnv, gpeTbx)
+// AnnotateSNV 注释SNV的基因功能及数据库，fbTbxs为额外的FilterBased数据库，如按区间拆分的数据库文件
+func (this *Annotator) AnnotateSNV(snv *pkg.SNV, fbTbxs ...*bix.Bix) (SnvResult, error) {
+	result, err := this.AnnotateGene(snv)
 	if err != nil {
-		annoInfo.Error = err
-		return annoInfo
+		return result, err
+	}
+	result.FilterBased = make(map[string]any)
+	for _, tbxs := range [][]*bix.Bix{this.fbTbxs, fbTbxs} {
+		for _, tbx := range tbxs {
+			anno, err := db.AnnoFilterBased(snv, tbx)
+			if err != nil {
+				return result, err
+			}
+			for key, val := range anno {
+				result.FilterBased[key] = val
+			}
+		}
+	}
+	for _, ldb := range this.fbLDBs {
+		anno, err := db.AnnoFilterBasedLevelDB(snv, ldb)
+		if err != nil {
+			return result, err
+		}
+		for key, val := range anno {
+			result.FilterBased[key] = val
+		}
 	}
-	annoInfo.AddAnno(anno)
-	for i, tbx := range gbTbxs {
-		anno, err = db.AnnoRegionBased(cnv, tbx, overlap, dbnames[i])
+	result.PositionBased = make(map[string]float64)
+	for _, positionBased := range this.ScoreDBs {
+		score, ok, err := positionBased.Anno(snv)
 		if err != nil {
-			annoInfo.Error = err
-			return annoInfo
+			return result, err
 		}
-		annoInfo.AddAnno(anno)
+		if ok {
+			result.PositionBased[positionBased.Key] = score
+		}
+	}
+	for _, transAnno := range result.Transcripts {
+		result.GeneBased = this.annoGeneBased(result.GeneBased, transAnno.Gene, transAnno.GeneID)
+	}
+	result.RegionBased, result.RegionInfo, err = this.annoRegionBaseds(snv)
+	return result, err
+}
+
+// AnnotateCNV 注释CNV的基因区域及RegionBased数据库
+func (this *Annotator) AnnotateCNV(cnv *pkg.CNV) (CnvResult, error) {
+	var err error
+	result := CnvResult{PK: cnv.PK()}
+	result.Transcripts, err = this.AnnoCnv(cnv, this.gpeTbx)
+	if err != nil {
+		return result, err
+	}
+	for _, transAnno := range result.Transcripts {
+		result.GeneBased = this.annoGeneBased(result.GeneBased, transAnno.Gene, transAnno.GeneID)
+	}
+	result.RegionBased, result.RegionInfo, err = this.annoRegionBaseds(cnv)
+	return result, err
+}
+
+// SnvWorker 从snvs读取变异注释，结果写入results
+func (this *Annotator) SnvWorker(snvs chan *pkg.SNV, fbTbxs []*bix.Bix, results chan SnvResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for snv := range snvs {
+		result, err := this.AnnotateSNV(snv, fbTbxs...)
+		result.Error = err
+		results <- result
 	}
-	return annoInfo
 }
 
-func AnnoCnvWorker(cnvs chan *pkg.CNV, gpeTbx *bix.Bix, rbTbxs []*bix.Bix, dbnames []string, overlap float64, result chan AnnoInfo, wg *sync.WaitGroup) {
+// CnvWorker 从cnvs读取变异注释，结果写入results
+func (this *Annotator) CnvWorker(cnvs chan *pkg.CNV, results chan CnvResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for cnv := range cnvs {
-		result <- AnnoCnv(cnv, gpeTbx, rbTbxs, dbnames, overlap)
+		result, err := this.AnnotateCNV(cnv)
+		result.Error = err
+		results <- result
 	}
 }
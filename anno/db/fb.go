@@ -7,19 +7,29 @@ import (
 	"github.com/brentp/irelate/interfaces"
 )
 
+// AnnoFilterBased 注释FilterBased数据库，变异与数据库记录均精简等位后比较，数据库中未拆分的多等位位点也能匹配
 func AnnoFilterBased(variant pkg.IVariant, tbx *bix.Bix) (map[string]any, error) {
 	query, err := tbx.Query(variant)
 	if err != nil {
 		return map[string]any{}, err
 	}
 	annoInfo := make(map[string]any)
+	pos, ref, alt := pkg.TrimAlleles(int(variant.Start())+1, variant.Ref(), variant.Alt()[0])
 	for v, e := query.Next(); e == nil; v, e = query.Next() {
 		v := v.(interfaces.IVariant)
-		if variant.Chrom() == v.Chrom() && variant.Start() == v.Start() && variant.End() == v.End() && variant.Ref() == v.Ref() && variant.Alt()[0] == v.Alt()[0] {
+		if variant.Chrom() != v.Chrom() {
+			continue
+		}
+		// 数据库中的多等位位点，取与变异ALT对应的值
+		for i, dbAlt := range v.Alt() {
+			dbPos, dbRef, dbAlt := pkg.TrimAlleles(int(v.Start())+1, v.Ref(), dbAlt)
+			if dbPos != pos || dbRef != ref || dbAlt != alt {
+				continue
+			}
 			for _, key := range v.Info().Keys() {
 				val, err := v.Info().Get(key)
 				if err == nil {
-					annoInfo[key] = val
+					annoInfo[key] = pkg.AlleleValue(val, tbx.VReader.Header.Infos[key], i, len(v.Alt()))
 				}
 			}
 		}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"open-anno/pkg"
+	"strings"
+
+	"github.com/brentp/vcfgo"
+)
+
+// GENEBASED_ID_KEYS GeneBased数据库以Entrez ID为键时第一列的列名，其他列名视为以基因名称为键
+var GENEBASED_ID_KEYS = []string{"GeneID", "Gene_ID", "EntrezID", "Entrez_ID", "gene_id"}
+
+// GeneBased 基因水平的数据库，TSV格式，第一行为表头，第一列为Entrez ID或基因名称
+type GeneBased struct {
+	Name   string              // 数据库名称，INFO字段名为Name_列名
+	ByID   bool                // 是否以Entrez ID为键
+	Fields []string            // 除第一列外的列名
+	Rows   map[string][]string // 以Entrez ID或基因名称为键
+}
+
+// ReadGeneBased 读取GeneBased数据库
+func ReadGeneBased(infile string, dbname string) (*GeneBased, error) {
+	reader, err := pkg.NewIOReader(infile)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	scanner := pkg.NewCSVScanner(reader)
+	if len(scanner.FieldNames) < 2 {
+		return nil, fmt.Errorf("GeneBased database requires a key column and at least one value column: %s", infile)
+	}
+	geneBased := &GeneBased{
+		Name:   dbname,
+		ByID:   pkg.FindArr(GENEBASED_ID_KEYS, scanner.FieldNames[0]) >= 0,
+		Fields: scanner.FieldNames[1:],
+		Rows:   make(map[string][]string),
+	}
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != len(scanner.FieldNames) {
+			return geneBased, fmt.Errorf("error field numbers of %s: %s", infile, scanner.Text())
+		}
+		geneBased.Rows[fields[0]] = fields[1:]
+	}
+	return geneBased, scanner.Err()
+}
+
+// Key 列对应的INFO字段名
+func (this GeneBased) Key(field string) string {
+	return fmt.Sprintf("%s_%s", this.Name, field)
+}
+
+// Anno 基因在数据库中的值，以INFO字段名为键，基因不在数据库中时返回false
+func (this GeneBased) Anno(gene, geneID string) (map[string]string, bool) {
+	key := gene
+	if this.ByID {
+		key = geneID
+	}
+	row, ok := this.Rows[key]
+	if !ok {
+		return map[string]string{}, false
+	}
+	anno := make(map[string]string)
+	for i, field := range this.Fields {
+		if row[i] != "" {
+			anno[this.Key(field)] = row[i]
+		}
+	}
+	return anno, true
+}
+
+// HeaderInfos 各列的VcfHeaderInfo
+func (this GeneBased) HeaderInfos() []*vcfgo.Info {
+	infos := make([]*vcfgo.Info, len(this.Fields))
+	for i, field := range this.Fields {
+		infos[i] = &vcfgo.Info{
+			Id:          this.Key(field),
+			Description: fmt.Sprintf("%s of %s, one value per gene in the same order as GENE", field, this.Name),
+			Number:      ".",
+			Type:        "String",
+		}
+	}
+	return infos
+}
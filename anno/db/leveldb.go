@@ -3,14 +3,19 @@ package db
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"open-anno/pkg"
 
 	"github.com/brentp/vcfgo"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
+// LEVELDB_HEADER_KEY LevelDB中VcfHeaderInfo的键
+const LEVELDB_HEADER_KEY = "header:info"
+
+// GetHeaderLevelDB 读取LevelDB中的VcfHeaderInfo
 func GetHeaderLevelDB(db *leveldb.DB) ([]*vcfgo.Info, error) {
-	val, err := db.Get([]byte("header:info"), nil)
+	val, err := db.Get([]byte(LEVELDB_HEADER_KEY), nil)
 	if err != nil {
 		return []*vcfgo.Info{}, err
 	}
@@ -22,9 +27,35 @@ func GetHeaderLevelDB(db *leveldb.DB) ([]*vcfgo.Info, error) {
 	return headInfo, nil
 }
 
+// PutHeaderLevelDB 写入VcfHeaderInfo
+func PutHeaderLevelDB(db *leveldb.DB, infos []*vcfgo.Info) error {
+	val, err := json.Marshal(infos)
+	if err != nil {
+		return err
+	}
+	return db.Put([]byte(LEVELDB_HEADER_KEY), val, nil)
+}
+
+// FilterBasedLevelDBKey LevelDB中变异的键，FORMAT=Chrom:Pos:Ref:Alt，等位经pkg.TrimAlleles精简
+func FilterBasedLevelDBKey(variant pkg.IVariant) string {
+	pos, ref, alt := pkg.TrimAlleles(int(variant.Start())+1, variant.Ref(), variant.Alt()[0])
+	return fmt.Sprintf("%s:%d:%s:%s", variant.Chrom(), pos, ref, alt)
+}
+
+// PutFilterBasedLevelDB 将变异的注释写入batch，以FilterBasedLevelDBKey为键
+func PutFilterBasedLevelDB(batch *leveldb.Batch, variant pkg.IVariant, annoInfo map[string]any) error {
+	val, err := json.Marshal(annoInfo)
+	if err != nil {
+		return err
+	}
+	batch.Put([]byte(FilterBasedLevelDBKey(variant)), val)
+	return nil
+}
+
+// AnnoFilterBasedLevelDB 按FilterBasedLevelDBKey精确匹配LevelDB中的注释，不存在时返回空结果
 func AnnoFilterBasedLevelDB(variant pkg.IVariant, db *leveldb.DB) (map[string]any, error) {
 	annoInfo := make(map[string]any)
-	val, err := db.Get([]byte(variant.PK()), nil)
+	val, err := db.Get([]byte(FilterBasedLevelDBKey(variant)), nil)
 	if err != nil {
 		if errors.Is(err, leveldb.ErrNotFound) {
 			return annoInfo, nil
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"fmt"
+	"open-anno/pkg"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/brentp/bix"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/brentp/vcfgo"
+)
+
+const (
+	Score_MEAN = "mean" // 变异覆盖的碱基打分的均值
+	Score_MAX  = "max"  // 变异覆盖的碱基打分的最大值
+)
+
+// PositionBasedConf 打分数据库文件及输出的INFO字段
+type PositionBasedConf struct {
+	File      string
+	Key       string // INFO字段名
+	Aggregate string // 变异覆盖多个碱基时的汇总方式，mean或max
+}
+
+// ParsePositionBasedConf 解析打分数据库，FORMAT=[Key[:Aggregate]=]File，未指定Key时使用文件名第一个.之前的部分
+func ParsePositionBasedConf(spec string) (PositionBasedConf, error) {
+	conf := PositionBasedConf{File: spec, Key: strings.Split(path.Base(spec), ".")[0], Aggregate: Score_MEAN}
+	if name, file, found := strings.Cut(spec, "="); found {
+		conf.File = file
+		conf.Key, conf.Aggregate, found = strings.Cut(name, ":")
+		if !found {
+			conf.Aggregate = Score_MEAN
+		}
+	}
+	if conf.Aggregate != Score_MEAN && conf.Aggregate != Score_MAX {
+		return conf, fmt.Errorf("score aggregate should be mean or max: %s", spec)
+	}
+	if conf.Key == "" || conf.File == "" {
+		return conf, fmt.Errorf("score database should be [Key[:Aggregate]=]File: %s", spec)
+	}
+	return conf, nil
+}
+
+// PositionBased 碱基水平的打分数据库，如phyloP、phastCons、GERP，为bigWig或tabix索引的bedGraph
+type PositionBased struct {
+	PositionBasedConf
+	bw  *pkg.BigWig
+	tbx *bix.Bix
+}
+
+// OpenPositionBased 打开打分数据库，.bw/.bigWig后缀为bigWig，其他为bedGraph
+func OpenPositionBased(conf PositionBasedConf) (*PositionBased, error) {
+	var err error
+	positionBased := &PositionBased{PositionBasedConf: conf}
+	if pkg.IsBigWig(conf.File) {
+		positionBased.bw, err = pkg.OpenBigWig(conf.File)
+	} else {
+		positionBased.tbx, err = bix.New(conf.File)
+	}
+	return positionBased, err
+}
+
+// Close 关闭文件句柄
+func (this *PositionBased) Close() {
+	if this.bw != nil {
+		this.bw.Close()
+	}
+	if this.tbx != nil {
+		this.tbx.Close()
+	}
+}
+
+// query 查询与区间[start, end)重叠的打分区间
+func (this *PositionBased) query(chrom string, start, end int) ([]pkg.ScoreInterval, error) {
+	if this.bw != nil {
+		return this.bw.Query(chrom, start, end)
+	}
+	intervals := make([]pkg.ScoreInterval, 0)
+	query, err := this.tbx.Query(pkg.NewPosition(chrom, start+1, end))
+	if err != nil {
+		return intervals, err
+	}
+	defer query.Close()
+	for v, e := query.Next(); e == nil; v, e = query.Next() {
+		region := v.(interfaces.IPosition)
+		if region.Chrom() != chrom || int(region.Start()) >= end || int(region.End()) <= start {
+			continue
+		}
+		fields := strings.Split(fmt.Sprintf("%s", v), "\t")
+		if len(fields) < 4 {
+			return intervals, fmt.Errorf("bedGraph requires 4 columns: %s", v)
+		}
+		score, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return intervals, err
+		}
+		intervals = append(intervals, pkg.ScoreInterval{Start: int(region.Start()), End: int(region.End()), Score: score})
+	}
+	return intervals, nil
+}
+
+// Anno 变异位置的打分，变异覆盖多个碱基(如缺失)时按Aggregate汇总，插入取插入位置前的碱基，无打分时返回false
+func (this *PositionBased) Anno(variant pkg.IVariant) (float64, bool, error) {
+	annoVar := variant.AnnoVariant()
+	start, end := annoVar.Start-1, annoVar.End
+	intervals, err := this.query(annoVar.Chrom, start, end)
+	if err != nil || len(intervals) == 0 {
+		return 0, false, err
+	}
+	var sum, max float64
+	var count int
+	for _, interval := range intervals {
+		bases := pkg.Min(interval.End, end) - pkg.Max(interval.Start, start)
+		if count == 0 || interval.Score > max {
+			max = interval.Score
+		}
+		sum += interval.Score * float64(bases)
+		count += bases
+	}
+	if this.Aggregate == Score_MAX {
+		return max, true, nil
+	}
+	return sum / float64(count), true, nil
+}
+
+// HeaderInfo 打分的VcfHeaderInfo
+func (this *PositionBased) HeaderInfo() *vcfgo.Info {
+	return &vcfgo.Info{
+		Id:          this.Key,
+		Description: fmt.Sprintf("Score at variant position, %s of the reference bases covered by deletion", this.Aggregate),
+		Number:      "1",
+		Type:        "Float",
+	}
+}
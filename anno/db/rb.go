@@ -3,31 +3,116 @@ package db
 import (
 	"fmt"
 	"open-anno/pkg"
+	"strconv"
 	"strings"
 
 	"github.com/brentp/bix"
 	"github.com/brentp/irelate/interfaces"
 )
 
-func AnnoRegionBased(variant pkg.IVariant, tbx *bix.Bix, overlap float64, dbname string) (map[string]any, error) {
+const (
+	Overlap_QUERY      = "query"      // 变异被覆盖的比例
+	Overlap_TARGET     = "target"     // 数据库区域被覆盖的比例
+	Overlap_RECIPROCAL = "reciprocal" // 变异及数据库区域被覆盖比例的较小值
+	Overlap_JACCARD    = "jaccard"    // 交集长度/并集长度
+	Overlap_ANY        = "any"        // 有重叠即可，比例同query
+)
+
+// Overlap RegionBased数据库的重叠方式及最小重叠比例
+type Overlap struct {
+	Mode     string  `json:"mode"`
+	Fraction float64 `json:"fraction"`
+}
+
+// ParseOverlap 解析重叠方式，FORMAT=Mode[:Fraction]，未指定比例时使用fraction
+func ParseOverlap(text string, fraction float64) (Overlap, error) {
+	mode, value, found := strings.Cut(text, ":")
+	overlap := Overlap{Mode: mode, Fraction: fraction}
+	switch mode {
+	case Overlap_QUERY, Overlap_TARGET, Overlap_RECIPROCAL, Overlap_JACCARD, Overlap_ANY:
+	default:
+		return overlap, fmt.Errorf("unknown overlap mode: %s", mode)
+	}
+	if found {
+		var err error
+		overlap.Fraction, err = strconv.ParseFloat(value, 64)
+		if err != nil || overlap.Fraction < 0 || overlap.Fraction > 1 {
+			return overlap, fmt.Errorf("overlap fraction should be in [0, 1]: %s", text)
+		}
+	}
+	return overlap, nil
+}
+
+// Compute 计算变异与数据库区域的重叠比例，区间均为0-based半开区间，无重叠时返回false
+func (this Overlap) Compute(variant, region interfaces.IPosition) (float64, bool) {
+	start, end := pkg.Max(variant.Start(), region.Start()), pkg.Min(variant.End(), region.End())
+	if variant.Chrom() != region.Chrom() || start >= end {
+		return 0, false
+	}
+	olen := float64(end - start)
+	qlen := float64(pkg.Max(variant.End()-variant.Start(), 1))
+	tlen := float64(pkg.Max(region.End()-region.Start(), 1))
+	var fraction float64
+	switch this.Mode {
+	case Overlap_TARGET:
+		fraction = olen / tlen
+	case Overlap_RECIPROCAL:
+		fraction = pkg.Min(olen/qlen, olen/tlen)
+	case Overlap_JACCARD:
+		fraction = olen / (qlen + tlen - olen)
+	case Overlap_ANY:
+		return olen / qlen, true
+	default:
+		fraction = olen / qlen
+	}
+	return fraction, fraction >= this.Fraction
+}
+
+// RegionRecord 与变异重叠的区域数据库记录
+type RegionRecord struct {
+	Chrom   string         `json:"chrom"`
+	Start   int            `json:"start"` // 1-based
+	End     int            `json:"end"`
+	Fields  map[string]any `json:"fields"`  // 按schema解析的各列，以INFO字段名为键
+	Overlap float64        `json:"overlap"` // 按数据库重叠方式计算的重叠比例
+}
+
+// AnnoRegionBased 返回按overlap与变异重叠的区域数据库记录
+func AnnoRegionBased(variant pkg.IVariant, tbx *bix.Bix, overlap Overlap, schema RegionSchema) ([]RegionRecord, error) {
+	records := make([]RegionRecord, 0)
 	query, err := tbx.Query(variant)
 	if err != nil {
-		return map[string]any{}, err
+		return records, err
 	}
-	infos := make([]string, 0)
+	defer query.Close()
 	for v, e := query.Next(); e == nil; v, e = query.Next() {
-		info := strings.Split(fmt.Sprintf("%s", v), "\t")[3]
-		v := v.(interfaces.IPosition)
-		if variant.End() >= v.Start() && variant.Start() <= v.End() {
-			vlen := variant.End() - variant.Start()
-			olen := pkg.Min(variant.End(), v.End()) - pkg.Max(variant.Start(), v.Start())
-			if float64(olen)/float64(vlen) >= overlap {
-				infos = append(infos, info)
-			}
+		region := v.(interfaces.IPosition)
+		fraction, ok := overlap.Compute(variant, region)
+		if !ok {
+			continue
 		}
+		fields, err := schema.Fields(fmt.Sprintf("%s", v))
+		if err != nil {
+			return records, err
+		}
+		records = append(records, RegionRecord{
+			Chrom:   region.Chrom(),
+			Start:   int(region.Start()) + 1,
+			End:     int(region.End()),
+			Fields:  fields,
+			Overlap: fraction,
+		})
+	}
+	return records, nil
+}
+
+// RegionOverlaps 记录的重叠比例，以|连接
+func RegionOverlaps(records []RegionRecord) string {
+	overlaps := make([]string, len(records))
+	for i, record := range records {
+		overlaps[i] = strconv.FormatFloat(record.Overlap, 'f', 4, 64)
 	}
-	query.Close()
-	return map[string]any{dbname: strings.Join(infos, "|")}, nil
+	return strings.Join(overlaps, "|")
 }
 
 // // AnnoRegion注释SNV FilterBased
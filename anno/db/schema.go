@@ -0,0 +1,204 @@
+package db
+
+import (
+	"fmt"
+	"open-anno/pkg"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brentp/vcfgo"
+)
+
+const (
+	Aggregate_JOIN  = "join"  // 所有记录的值以|连接，缺失值为.
+	Aggregate_MAX   = "max"   // 最大值，仅用于Integer、Float列
+	Aggregate_MIN   = "min"   // 最小值，仅用于Integer、Float列
+	Aggregate_COUNT = "count" // 有值的记录数
+	Aggregate_FIRST = "first" // 第一个有值的记录的值
+)
+
+// REGION_SCHEMA_SUFFIX RegionBased数据库schema文件的后缀，schema文件与数据库文件位于同一目录
+const REGION_SCHEMA_SUFFIX = ".schema"
+
+// RegionColumn RegionBased数据库中输出为INFO字段的列
+type RegionColumn struct {
+	Column      int    // 1-based列号，不小于4
+	ID          string // INFO字段名
+	Type        string // Integer, Float, String
+	Aggregate   string // 多个记录重叠时的汇总方式
+	Description string
+}
+
+// Value 按列类型解析值，值为空或.时返回false
+func (this RegionColumn) Value(text string) (any, bool, error) {
+	if text == "" || text == "." {
+		return nil, false, nil
+	}
+	switch this.Type {
+	case "Integer":
+		val, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, false, fmt.Errorf("column %d of %s is not Integer: %s", this.Column, this.ID, text)
+		}
+		return val, true, nil
+	case "Float":
+		val, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("column %d of %s is not Float: %s", this.Column, this.ID, text)
+		}
+		return val, true, nil
+	}
+	return text, true, nil
+}
+
+// HeaderInfo 列的VcfHeaderInfo
+func (this RegionColumn) HeaderInfo() *vcfgo.Info {
+	info := &vcfgo.Info{Id: this.ID, Description: this.Description, Number: "1", Type: this.Type}
+	switch this.Aggregate {
+	case Aggregate_JOIN:
+		info.Number, info.Type = ".", "String"
+	case Aggregate_COUNT:
+		info.Type = "Integer"
+	}
+	return info
+}
+
+// RegionSchema RegionBased数据库输出的列
+type RegionSchema []RegionColumn
+
+// DefaultRegionSchema 无schema文件时的默认schema，第4列以数据库名称为INFO字段名，所有记录以|连接
+func DefaultRegionSchema(dbname string) RegionSchema {
+	return RegionSchema{{Column: 4, ID: dbname, Type: "String", Aggregate: Aggregate_JOIN, Description: dbname}}
+}
+
+// ReadRegionSchema 读取数据库文件rbFile对应的schema文件，schema文件不存在时使用DefaultRegionSchema
+// schema文件为TSV格式，#开头的行为注释，每行FORMAT=Column\tID\tType\tAggregate[\tDescription]
+func ReadRegionSchema(rbFile string, dbname string) (RegionSchema, error) {
+	schemaFile := rbFile + REGION_SCHEMA_SUFFIX
+	if _, err := os.Stat(schemaFile); os.IsNotExist(err) {
+		return DefaultRegionSchema(dbname), nil
+	}
+	reader, err := pkg.NewIOReader(schemaFile)
+	if err != nil {
+		return RegionSchema{}, err
+	}
+	defer reader.Close()
+	schema := make(RegionSchema, 0)
+	ids := make(map[string]bool)
+	scanner := pkg.NewIOScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			return schema, fmt.Errorf("error schema line of %s: %s", schemaFile, line)
+		}
+		column := RegionColumn{ID: fields[1], Type: fields[2], Aggregate: fields[3], Description: fmt.Sprintf("%s column %s", dbname, fields[0])}
+		if len(fields) > 4 && fields[4] != "" {
+			column.Description = fields[4]
+		}
+		column.Column, err = strconv.Atoi(fields[0])
+		if err != nil || column.Column < 4 {
+			return schema, fmt.Errorf("column of %s should be an integer not less than 4: %s", schemaFile, fields[0])
+		}
+		switch column.Type {
+		case "Integer", "Float", "String":
+		default:
+			return schema, fmt.Errorf("unknown type of %s: %s", schemaFile, column.Type)
+		}
+		switch column.Aggregate {
+		case Aggregate_JOIN, Aggregate_COUNT, Aggregate_FIRST:
+		case Aggregate_MAX, Aggregate_MIN:
+			if column.Type == "String" {
+				return schema, fmt.Errorf("aggregate %s of %s requires Integer or Float column: %s", column.Aggregate, schemaFile, column.ID)
+			}
+		default:
+			return schema, fmt.Errorf("unknown aggregate of %s: %s", schemaFile, column.Aggregate)
+		}
+		if column.ID == "" || ids[column.ID] {
+			return schema, fmt.Errorf("empty or duplicate ID of %s: %s", schemaFile, column.ID)
+		}
+		ids[column.ID] = true
+		schema = append(schema, column)
+	}
+	if len(schema) == 0 {
+		return schema, fmt.Errorf("no column in schema: %s", schemaFile)
+	}
+	return schema, scanner.Err()
+}
+
+// Fields 解析BED记录中schema的各列，以INFO字段名为键，缺失值不包含在内
+func (this RegionSchema) Fields(line string) (map[string]any, error) {
+	fields := make(map[string]any)
+	texts := strings.Split(line, "\t")
+	for _, column := range this {
+		if column.Column > len(texts) {
+			continue
+		}
+		val, ok, err := column.Value(texts[column.Column-1])
+		if err != nil {
+			return fields, err
+		}
+		if ok {
+			fields[column.ID] = val
+		}
+	}
+	return fields, nil
+}
+
+// Aggregate 按schema汇总重叠的记录，无值的列不包含在内
+func (this RegionSchema) Aggregate(records []RegionRecord) map[string]any {
+	info := make(map[string]any)
+	for _, column := range this {
+		values := make([]any, 0)
+		texts := make([]string, len(records))
+		for i, record := range records {
+			texts[i] = "."
+			if val, ok := record.Fields[column.ID]; ok {
+				values = append(values, val)
+				texts[i] = fmt.Sprint(val)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		switch column.Aggregate {
+		case Aggregate_JOIN:
+			info[column.ID] = strings.Join(texts, "|")
+		case Aggregate_COUNT:
+			info[column.ID] = len(values)
+		case Aggregate_FIRST:
+			info[column.ID] = values[0]
+		case Aggregate_MAX, Aggregate_MIN:
+			best := values[0]
+			for _, val := range values[1:] {
+				if column.Aggregate == Aggregate_MAX && regionFloat(val) > regionFloat(best) ||
+					column.Aggregate == Aggregate_MIN && regionFloat(val) < regionFloat(best) {
+					best = val
+				}
+			}
+			info[column.ID] = best
+		}
+	}
+	return info
+}
+
+// regionFloat Integer、Float列的值转换为float64用于比较
+func regionFloat(val any) float64 {
+	if v, ok := val.(int); ok {
+		return float64(v)
+	}
+	return val.(float64)
+}
+
+// HeaderInfos schema各列的VcfHeaderInfo
+func (this RegionSchema) HeaderInfos() []*vcfgo.Info {
+	infos := make([]*vcfgo.Info, len(this))
+	for i, column := range this {
+		infos[i] = column.HeaderInfo()
+	}
+	return infos
+}
@@ -14,20 +14,32 @@ type CnvTransAnno struct {
 	GeneID     string `json:"gene_id"`
 	Transcript string `json:"transcript"`
 	CDS        string `json:"cds"`
+	Exon       string `json:"exon"`   // such as: 3/12, 3-5/12
+	Intron     string `json:"intron"` // such as: 2/11, 2-4/11
 	Region     string `json:"region"`
 	Strand     string `json:"strand"`
 	Position   string `json:"position"`
+	Canonical  string `json:"canonical"`
 }
 
-func NewCnvTransAnno(trans pkg.Transcript) CnvTransAnno {
+// Detail CNV转录本注释结果，FORMAT=Gene:GeneID:Transcript:Strand:Region:CDS:Exon:Position
+func (this CnvTransAnno) Detail() string {
+	return fmt.Sprintf("%s:%s:%s:%s:%s:%s:%s:%s",
+		this.Gene, this.GeneID, this.Transcript, this.Strand, this.Region, this.CDS, this.Exon, this.Position,
+	)
+}
+
+func (this Options) NewCnvTransAnno(trans pkg.Transcript) CnvTransAnno {
 	transAnno := CnvTransAnno{
 		Gene:       trans.Gene,
 		GeneID:     trans.GeneID,
-		Transcript: trans.Name,
+		Transcript: this.Accessions.Transcript(trans),
 		Strand:     trans.Strand,
 		CDS:        ".",
+		Exon:       ".",
 		Region:     ".",
 		Position:   fmt.Sprintf("%d-%d", trans.TxStart, trans.TxEnd),
+		Canonical:  this.RepTrans.Source(trans),
 	}
 	if transAnno.GeneID == "" {
 		transAnno.GeneID = "."
@@ -35,88 +47,131 @@ func NewCnvTransAnno(trans pkg.Transcript) CnvTransAnno {
 	return transAnno
 }
 
-func AnnoCnv(cnv *pkg.CNV, tbx *bix.Bix) (map[string]any, error) {
+// AnnoCnvTrans 注释CNV在单个转录本上的区域、CDS及Exon范围
+func (this Options) AnnoCnvTrans(cnv pkg.AnnoVariant, trans pkg.Transcript) CnvTransAnno {
+	var cdss, utr3s, utr5s pkg.Regions
+	var cdsCount int
+	regions := make(pkg.Regions, len(trans.Regions))
+	copy(regions, trans.Regions)
+	if trans.Strand == "-" {
+		sort.Sort(sort.Reverse(regions))
+	}
+	for _, region := range regions {
+		if region.Type == pkg.RType_CDS {
+			cdsCount++
+		}
+		if cnv.Start <= region.End && cnv.End >= region.Start {
+			if region.Type == pkg.RType_CDS {
+				cdss = append(cdss, region)
+			}
+			if region.Type == pkg.RType_UTR {
+				if region.Order == 3 {
+					utr3s = append(utr3s, region)
+				} else {
+					utr5s = append(utr5s, region)
+				}
+			}
+		}
+	}
+	transAnno := this.NewCnvTransAnno(trans)
+	exon, intron := trans.ExonIntron(cnv)
+	if exon != "" {
+		transAnno.Exon = exon
+	}
+	transAnno.Intron = intron
+	if trans.IsUnk() {
+		transAnno.Region = "ncRNA_intronic"
+		if exon != "" {
+			transAnno.Region = "ncRNA_exonic"
+		}
+	} else if len(cdss) > 0 {
+		if len(utr5s) > 0 {
+			transAnno.Region = "UTR5_CDS"
+			if len(utr3s) > 0 {
+				transAnno.Region = "CDNA"
+			}
+		} else {
+			transAnno.Region = "CDS"
+			if len(utr3s) > 0 {
+				transAnno.Region = "CDS_UTR3"
+			}
+		}
+		if len(cdss) == 1 {
+			transAnno.CDS = fmt.Sprintf("CDS%d/%d", cdss[0].Order, cdsCount)
+		} else {
+			transAnno.CDS = fmt.Sprintf("CDS%d_%d/%d", cdss[0].Order, cdss[len(cdss)-1].Order, cdsCount)
+		}
+	} else {
+		if len(utr5s) > 0 {
+			transAnno.Region = "UTR5"
+			if len(utr3s) > 0 {
+				transAnno.Region = "ncRNA"
+			}
+		} else {
+			transAnno.Region = "intronic"
+			if len(utr3s) > 0 {
+				transAnno.Region = "UTR3"
+			}
+		}
+	}
+	if cnv.Start <= trans.TxStart && cnv.End >= trans.TxEnd {
+		transAnno.Region = "transcript"
+	}
+	return transAnno
+}
+
+// AnnoCnv 注释CNV在所有重叠转录本上的区域，并按转录本模式筛选
+func (this Options) AnnoCnv(cnv *pkg.CNV, tbx *bix.Bix) ([]CnvTransAnno, error) {
 	annoVar := cnv.AnnoVariant()
 	transAnnos := make([]CnvTransAnno, 0)
 	query, err := tbx.Query(cnv)
 	if err != nil {
-		return map[string]any{}, err
+		return transAnnos, err
 	}
 	for v, e := query.Next(); e == nil; v, e = query.Next() {
 		trans, err := pkg.NewTranscript(fmt.Sprintf("%s", v))
 		if err != nil {
-			return map[string]any{}, err
+			query.Close()
+			return transAnnos, err
 		}
-		if !trans.IsUnk() {
-			trans.SetGeneID()
+		if trans.TxStart <= annoVar.End && trans.TxEnd >= annoVar.Start {
+			trans.SetGeneID(this.GeneSymbols)
 			trans.SetRegions()
-			var cdss, utr3s, utr5s pkg.Regions
-			var cdsCount int
-			regions := trans.Regions
-			if trans.Strand == "-" {
-				sort.Sort(sort.Reverse(regions))
-			}
-			for _, region := range regions {
-				if region.Type == pkg.RType_CDS {
-					cdsCount++
-				}
-				if annoVar.Start <= region.End && annoVar.End >= region.Start {
-					if region.Type == pkg.RType_CDS {
-						cdss = append(cdss, region)
-					}
-					if region.Type == pkg.RType_UTR {
-						if region.Order == 3 {
-							utr3s = append(utr3s, region)
-						} else {
-							utr5s = append(utr5s, region)
-						}
-					}
-				}
-			}
-			transAnno := NewCnvTransAnno(trans)
-			if len(cdss) > 0 {
-				if len(utr5s) > 0 {
-					transAnno.Region = "UTR5_CDS"
-					if len(utr3s) > 0 {
-						transAnno.Region = "CDNA"
-						if annoVar.Start <= trans.TxStart && annoVar.End >= trans.TxEnd {
-							transAnno.Region = "transcript"
-						}
-					}
-				} else {
-					transAnno.Region = "CDS"
-					if len(utr3s) > 0 {
-						transAnno.Region = "CDS_UTR3"
-					}
-				}
-				if len(cdss) == 1 {
-					transAnno.CDS = fmt.Sprintf("CDS%d/%d", cdss[0].Order, cdsCount)
-				} else {
-					transAnno.CDS = fmt.Sprintf("CDS%d_%d/%d", cdss[0].Order, cdss[len(cdss)-1].Order, cdsCount)
-				}
-			} else {
-				if len(utr5s) > 0 {
-					transAnno.Region = "UTR5"
-					if len(utr3s) > 0 {
-						transAnno.Region = "ncRNA"
-					}
-				} else {
-					transAnno.Region = "intronic"
-					if len(utr3s) > 0 {
-						transAnno.Region = "UTR3"
-					}
-				}
-			}
+			transAnnos = append(transAnnos, this.AnnoCnvTrans(annoVar, trans))
 		}
 	}
 	query.Close()
-	annoTexts := make([]string, 0)
+	return this.SelectCnvTransAnnos(transAnnos), nil
+}
+
+// CnvInfo 将CNV转录本注释结果合并为VCF INFO字段
+func CnvInfo(transAnnos []CnvTransAnno) map[string]any {
+	annoTexts, canonicals, exons, introns := make([]string, 0), make([]string, 0), make([]string, 0), make([]string, 0)
+	genes, geneIDs := make([]string, 0), make([]string, 0)
 	for _, transAnno := range transAnnos {
-		annoTexts = append(annoTexts, fmt.Sprintf("%s:%s:%s:%s:%s:%s:%s",
-			transAnno.Gene, transAnno.GeneID, transAnno.Transcript, transAnno.Strand, transAnno.Region, transAnno.CDS, transAnno.Position,
-		))
+		if pkg.FindArr(genes, transAnno.Gene) < 0 {
+			genes = append(genes, transAnno.Gene)
+			geneIDs = append(geneIDs, transAnno.GeneID)
+		}
+		annoTexts = append(annoTexts, transAnno.Detail())
+		if transAnno.Canonical != "" {
+			canonicals = append(canonicals, fmt.Sprintf("%s:%s", transAnno.Transcript, transAnno.Canonical))
+		}
+		if transAnno.Exon != "." {
+			exons = append(exons, fmt.Sprintf("%s:%s", transAnno.Transcript, transAnno.Exon))
+		}
+		if transAnno.Intron != "" {
+			introns = append(introns, fmt.Sprintf("%s:%s", transAnno.Transcript, transAnno.Intron))
+		}
+	}
+	return map[string]any{
+		"GENE":      strings.Join(genes, ","),
+		"GENE_ID":   strings.Join(geneIDs, ","),
+		"DETAIL":    strings.Join(annoTexts, ","),
+		"CANONICAL": strings.Join(canonicals, ","),
+		"EXON":      strings.Join(exons, ","),
+		"INTRON":    strings.Join(introns, ","),
 	}
-	return map[string]any{"DETAIl": strings.Join(annoTexts, ",")}, nil
 }
 
 // func AnnoCnvs(vcfFile string, gpeFile string, goroutines int) (anno.AnnoResult, error) {
@@ -0,0 +1,67 @@
+package gene
+
+import (
+	"open-anno/pkg"
+	"testing"
+)
+
+// 转录本均为chr1:1001-2000，3个exon: 1001-1200, 1401-1600, 1801-2000，编码转录本的CDS为1101-1900
+const (
+	testGenePredPlus  = "0\tNM_PLUS\tchr1\t+\t1000\t2000\t1100\t1900\t3\t1000,1400,1800,\t1200,1600,2000,\t0\tGENEP\tcmpl\tcmpl\t0,1,2,"
+	testGenePredMinus = "0\tNM_MINUS\tchr1\t-\t1000\t2000\t1100\t1900\t3\t1000,1400,1800,\t1200,1600,2000,\t0\tGENEM\tcmpl\tcmpl\t2,1,0,"
+	testGenePredNc    = "0\tNR_PLUS\tchr1\t+\t1000\t2000\t2000\t2000\t3\t1000,1400,1800,\t1200,1600,2000,\t0\tGENEN\tunk\tunk\t-1,-1,-1,"
+)
+
+func newTestTranscript(t *testing.T, line string) pkg.Transcript {
+	trans, err := pkg.NewTranscript(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trans.SetRegions()
+	return trans
+}
+
+func TestAnnoCnvTrans(t *testing.T) {
+	tests := []struct {
+		name       string
+		genePred   string
+		start, end int
+		region     string
+		cds        string
+		exon       string
+		intron     string
+	}{
+		{"plus CDS", testGenePredPlus, 1150, 1160, "CDS", "CDS1/3", "1/3", ""},
+		{"plus UTR5", testGenePredPlus, 1050, 1060, "UTR5", ".", "1/3", ""},
+		{"plus UTR3", testGenePredPlus, 1950, 1960, "UTR3", ".", "3/3", ""},
+		{"plus intronic", testGenePredPlus, 1300, 1310, "intronic", ".", ".", "1/2"},
+		{"plus multi-exon CDS", testGenePredPlus, 1150, 1500, "CDS", "CDS1_2/3", "1-2/3", "1/2"},
+		{"plus UTR5 and CDS", testGenePredPlus, 1050, 1500, "UTR5_CDS", "CDS1_2/3", "1-2/3", "1/2"},
+		{"plus CDS and UTR3", testGenePredPlus, 1500, 1950, "CDS_UTR3", "CDS2_3/3", "2-3/3", "2/2"},
+		{"plus UTR5 to UTR3", testGenePredPlus, 1050, 1950, "CDNA", "CDS1_3/3", "1-3/3", "1-2/2"},
+		{"plus whole transcript", testGenePredPlus, 900, 2100, "transcript", "CDS1_3/3", "1-3/3", "1-2/2"},
+		{"minus CDS", testGenePredMinus, 1150, 1160, "CDS", "CDS3/3", "3/3", ""},
+		{"minus UTR3", testGenePredMinus, 1050, 1060, "UTR3", ".", "3/3", ""},
+		{"minus UTR5", testGenePredMinus, 1950, 1960, "UTR5", ".", "1/3", ""},
+		{"minus intronic", testGenePredMinus, 1700, 1710, "intronic", ".", ".", "1/2"},
+		{"minus multi-exon CDS", testGenePredMinus, 1150, 1500, "CDS", "CDS2_3/3", "2-3/3", "2/2"},
+		{"minus UTR5 and CDS", testGenePredMinus, 1500, 1950, "UTR5_CDS", "CDS1_2/3", "1-2/3", "1/2"},
+		{"minus CDS and UTR3", testGenePredMinus, 1050, 1500, "CDS_UTR3", "CDS2_3/3", "2-3/3", "2/2"},
+		{"minus whole transcript", testGenePredMinus, 1001, 2000, "transcript", "CDS1_3/3", "1-3/3", "1-2/2"},
+		{"ncRNA exonic", testGenePredNc, 1150, 1160, "ncRNA_exonic", ".", "1/3", ""},
+		{"ncRNA intronic", testGenePredNc, 1300, 1310, "ncRNA_intronic", ".", ".", "1/2"},
+		{"ncRNA multi-exon", testGenePredNc, 1150, 1850, "ncRNA_exonic", ".", "1-3/3", "1-2/2"},
+		{"ncRNA whole transcript", testGenePredNc, 900, 2100, "transcript", ".", "1-3/3", "1-2/2"},
+	}
+	options := DefaultOptions()
+	for _, test := range tests {
+		trans := newTestTranscript(t, test.genePred)
+		cnv := pkg.AnnoVariant{Chrom: "chr1", Start: test.start, End: test.end, Ref: "N", Alt: "<DEL>"}
+		transAnno := options.AnnoCnvTrans(cnv, trans)
+		if transAnno.Region != test.region || transAnno.CDS != test.cds || transAnno.Exon != test.exon || transAnno.Intron != test.intron {
+			t.Errorf("%s: got %s %s %s %s, want %s %s %s %s", test.name,
+				transAnno.Region, transAnno.CDS, transAnno.Exon, transAnno.Intron, test.region, test.cds, test.exon, test.intron,
+			)
+		}
+	}
+}
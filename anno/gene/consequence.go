@@ -0,0 +1,186 @@
+package gene
+
+import (
+	"fmt"
+	"open-anno/pkg"
+	"strings"
+)
+
+const (
+	Impact_HIGH     = "HIGH"
+	Impact_MODERATE = "MODERATE"
+	Impact_LOW      = "LOW"
+	Impact_MODIFIER = "MODIFIER"
+)
+
+// Consequence Sequence Ontology 变异后果
+type Consequence struct {
+	Term      string `json:"term"`
+	Accession string `json:"accession"`
+	Impact    string `json:"impact"`
+}
+
+// SOConsequences SO术语及其登录号、影响等级，按严重程度从高到低排列
+var SOConsequences = []Consequence{
+	{Term: "transcript_ablation", Accession: "SO:0001893", Impact: Impact_HIGH},
+	{Term: "splice_acceptor_variant", Accession: "SO:0001574", Impact: Impact_HIGH},
+	{Term: "splice_donor_variant", Accession: "SO:0001575", Impact: Impact_HIGH},
+	{Term: "stop_gained", Accession: "SO:0001587", Impact: Impact_HIGH},
+	{Term: "frameshift_variant", Accession: "SO:0001589", Impact: Impact_HIGH},
+	{Term: "stop_lost", Accession: "SO:0001578", Impact: Impact_HIGH},
+	{Term: "start_lost", Accession: "SO:0002012", Impact: Impact_HIGH},
+	{Term: "inframe_insertion", Accession: "SO:0001821", Impact: Impact_MODERATE},
+	{Term: "inframe_deletion", Accession: "SO:0001822", Impact: Impact_MODERATE},
+	{Term: "missense_variant", Accession: "SO:0001583", Impact: Impact_MODERATE},
+	{Term: "protein_altering_variant", Accession: "SO:0001818", Impact: Impact_MODERATE},
+	{Term: "splice_donor_5th_base_variant", Accession: "SO:0001787", Impact: Impact_LOW},
+	{Term: "splice_region_variant", Accession: "SO:0001630", Impact: Impact_LOW},
+	{Term: "splice_polypyrimidine_tract_variant", Accession: "SO:0002169", Impact: Impact_LOW},
+	{Term: "stop_retained_variant", Accession: "SO:0001567", Impact: Impact_LOW},
+	{Term: "synonymous_variant", Accession: "SO:0001819", Impact: Impact_LOW},
+	{Term: "coding_sequence_variant", Accession: "SO:0001580", Impact: Impact_MODIFIER},
+	{Term: "mature_miRNA_variant", Accession: "SO:0001620", Impact: Impact_MODIFIER},
+	{Term: "5_prime_UTR_variant", Accession: "SO:0001623", Impact: Impact_MODIFIER},
+	{Term: "3_prime_UTR_variant", Accession: "SO:0001624", Impact: Impact_MODIFIER},
+	{Term: "non_coding_transcript_exon_variant", Accession: "SO:0001792", Impact: Impact_MODIFIER},
+	{Term: "intron_variant", Accession: "SO:0001627", Impact: Impact_MODIFIER},
+	{Term: "non_coding_transcript_variant", Accession: "SO:0001619", Impact: Impact_MODIFIER},
+	{Term: "upstream_gene_variant", Accession: "SO:0001631", Impact: Impact_MODIFIER},
+	{Term: "downstream_gene_variant", Accession: "SO:0001632", Impact: Impact_MODIFIER},
+	{Term: "feature_truncation", Accession: "SO:0001906", Impact: Impact_MODIFIER},
+	{Term: "intergenic_variant", Accession: "SO:0001628", Impact: Impact_MODIFIER},
+	{Term: "sequence_variant", Accession: "SO:0001060", Impact: Impact_MODIFIER},
+}
+
+// Consequences 一个转录本上的所有变异后果
+type Consequences []Consequence
+
+// Terms SO术语，以&连接
+func (this Consequences) Terms() string {
+	terms := make([]string, len(this))
+	for i, csq := range this {
+		terms[i] = csq.Term
+	}
+	return strings.Join(terms, "&")
+}
+
+// Accessions SO登录号，以&连接，如 SO_0001583&SO_0001630
+func (this Consequences) Accessions() string {
+	accessions := make([]string, len(this))
+	for i, csq := range this {
+		accessions[i] = strings.Replace(csq.Accession, ":", "_", 1)
+	}
+	return strings.Join(accessions, "&")
+}
+
+// Impact 最高的影响等级
+func (this Consequences) Impact() string {
+	if len(this) == 0 {
+		return ""
+	}
+	return this[0].Impact
+}
+
+// add 按严重程度顺序加入SO术语
+func (this Consequences) add(term string) Consequences {
+	for _, csq := range this {
+		if csq.Term == term {
+			return this
+		}
+	}
+	ncsqs := make(Consequences, 0, len(this)+1)
+	for _, csq := range SOConsequences {
+		if csq.Term == term {
+			ncsqs = append(ncsqs, csq)
+			continue
+		}
+		for _, old := range this {
+			if old.Term == csq.Term {
+				ncsqs = append(ncsqs, old)
+			}
+		}
+	}
+	return ncsqs
+}
+
+// NewConsequences 根据转录本注释结果(Region, Event)得到SO变异后果
+func (this Options) NewConsequences(transAnno TransAnno, snv pkg.AnnoVariant, trans pkg.Transcript) Consequences {
+	csqs := make(Consequences, 0)
+	for _, stype := range trans.SpliceTypes(snv, this.Splice) {
+		csqs = csqs.add(stype + "_variant")
+	}
+	spliceCount := len(csqs)
+	if trans.IsUnk() {
+		region, _, _ := trans.Region(snv.Start)
+		if region.Type == pkg.RType_INTRON {
+			csqs = csqs.add("intron_variant")
+			csqs = csqs.add("non_coding_transcript_variant")
+		} else {
+			csqs = csqs.add("non_coding_transcript_exon_variant")
+		}
+		if trans.IsMatureMiRNA() && ncExonic(snv, trans) {
+			csqs = csqs.add("mature_miRNA_variant")
+		}
+		return csqs
+	}
+	events := strings.Split(transAnno.Event, "_")
+	for _, event := range events {
+		switch event {
+		case "synonymous":
+			csqs = csqs.add("synonymous_variant")
+		case "stopretained":
+			csqs = csqs.add("stop_retained_variant")
+		case "missense":
+			csqs = csqs.add("missense_variant")
+		case "nonsense":
+			csqs = csqs.add("stop_gained")
+		case "startloss":
+			csqs = csqs.add("start_lost")
+		case "stoploss":
+			csqs = csqs.add("stop_lost")
+		case "frameshift":
+			csqs = csqs.add("frameshift_variant")
+		case "inframe":
+			switch events[0] {
+			case "ins":
+				csqs = csqs.add("inframe_insertion")
+			case "del":
+				csqs = csqs.add("inframe_deletion")
+			default:
+				csqs = csqs.add("protein_altering_variant")
+			}
+		case "deletion":
+			csqs = csqs.add("transcript_ablation")
+		}
+	}
+	switch transAnno.Region {
+	case "UTR5":
+		csqs = csqs.add("5_prime_UTR_variant")
+	case "UTR3":
+		csqs = csqs.add("3_prime_UTR_variant")
+	case "intronic":
+		csqs = csqs.add("intron_variant")
+	case "UpStream":
+		csqs = csqs.add("upstream_gene_variant")
+	case "DownStream":
+		csqs = csqs.add("downstream_gene_variant")
+	case "deletion":
+		csqs = csqs.add("feature_truncation")
+	case "exonic", "exonic_splicing":
+		if len(csqs) == spliceCount {
+			csqs = csqs.add("coding_sequence_variant")
+		}
+	}
+	if len(csqs) == 0 {
+		csqs = csqs.add("sequence_variant")
+	}
+	return csqs
+}
+
+// String FORMAT=Transcript:Terms:Accessions:Impact
+func (this Consequences) String(transcript string) string {
+	if len(this) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s:%s:%s", transcript, this.Terms(), this.Accessions(), this.Impact())
+}
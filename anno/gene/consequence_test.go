@@ -0,0 +1,133 @@
+package gene
+
+import (
+	"open-anno/pkg"
+	"testing"
+)
+
+func TestNewConsequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		genePred string
+		start    int
+		end      int
+		region   string
+		event    string
+		terms    string
+		impact   string
+	}{
+		{"synonymous", testGenePredPlus, 1150, 1150, "exonic", "synonymous", "synonymous_variant", Impact_LOW},
+		{"stop retained", testGenePredPlus, 1900, 1900, "exonic", "stopretained", "stop_retained_variant", Impact_LOW},
+		{"missense", testGenePredPlus, 1150, 1150, "exonic", "missense", "missense_variant", Impact_MODERATE},
+		{"nonsense", testGenePredPlus, 1150, 1150, "exonic", "nonsense", "stop_gained", Impact_HIGH},
+		{"start loss", testGenePredPlus, 1101, 1101, "exonic", "startloss", "start_lost", Impact_HIGH},
+		{"stop loss", testGenePredPlus, 1900, 1900, "exonic", "stoploss", "stop_lost", Impact_HIGH},
+		{"inframe insertion", testGenePredPlus, 1150, 1150, "exonic", "ins_inframe", "inframe_insertion", Impact_MODERATE},
+		{"inframe deletion", testGenePredPlus, 1150, 1152, "exonic", "del_inframe", "inframe_deletion", Impact_MODERATE},
+		{"inframe substitution", testGenePredPlus, 1150, 1152, "exonic", "sub_inframe", "protein_altering_variant", Impact_MODERATE},
+		{"frameshift stop loss", testGenePredPlus, 1899, 1900, "exonic", "del_frameshift_stoploss", "frameshift_variant&stop_lost", Impact_HIGH},
+		{"exonic without event", testGenePredPlus, 1150, 1150, "exonic", "", "coding_sequence_variant", Impact_MODIFIER},
+		{"UTR5", testGenePredPlus, 1050, 1050, "UTR5", "", "5_prime_UTR_variant", Impact_MODIFIER},
+		{"UTR3", testGenePredPlus, 1950, 1950, "UTR3", "", "3_prime_UTR_variant", Impact_MODIFIER},
+		{"intronic", testGenePredPlus, 1300, 1300, "intronic", "", "intron_variant", Impact_MODIFIER},
+		{"upstream", testGenePredPlus, 900, 900, "UpStream", "", "upstream_gene_variant", Impact_MODIFIER},
+		{"downstream", testGenePredPlus, 2100, 2100, "DownStream", "", "downstream_gene_variant", Impact_MODIFIER},
+		{"transcript deletion", testGenePredPlus, 900, 2100, "deletion", "deletion", "transcript_ablation&splice_acceptor_variant&splice_donor_variant&splice_donor_5th_base_variant&splice_region_variant&splice_polypyrimidine_tract_variant&feature_truncation", Impact_HIGH},
+		{"splice donor", testGenePredPlus, 1201, 1201, "splicing", "splicing", "splice_donor_variant", Impact_HIGH},
+		{"splice acceptor", testGenePredPlus, 1399, 1399, "splicing", "splicing", "splice_acceptor_variant", Impact_HIGH},
+		{"intronic splice region", testGenePredPlus, 1203, 1203, "splicing", "splicing", "splice_region_variant", Impact_LOW},
+		{"splice region in CDS", testGenePredPlus, 1199, 1199, "exonic_splicing", "missense", "missense_variant&splice_region_variant", Impact_MODERATE},
+		{"splice region without event", testGenePredPlus, 1199, 1199, "exonic_splicing", "", "splice_region_variant&coding_sequence_variant", Impact_LOW},
+		{"minus splice acceptor", testGenePredMinus, 1201, 1201, "splicing", "splicing", "splice_acceptor_variant", Impact_HIGH},
+		{"ncRNA exon", testGenePredNc, 1150, 1150, "ncRNA", "", "non_coding_transcript_exon_variant", Impact_MODIFIER},
+		{"ncRNA intron", testGenePredNc, 1300, 1300, "ncRNA", "", "intron_variant&non_coding_transcript_variant", Impact_MODIFIER},
+		{"no term", testGenePredPlus, 1150, 1150, "", "", "sequence_variant", Impact_MODIFIER},
+	}
+	options := DefaultOptions()
+	for _, test := range tests {
+		trans := newTestTranscript(t, test.genePred)
+		snv := pkg.AnnoVariant{Chrom: "chr1", Start: test.start, End: test.end, Ref: "A", Alt: "G"}
+		csqs := options.NewConsequences(TransAnno{Region: test.region, Event: test.event}, snv, trans)
+		if csqs.Terms() != test.terms || csqs.Impact() != test.impact {
+			t.Errorf("%s: got %s %s, want %s %s", test.name, csqs.Terms(), csqs.Impact(), test.terms, test.impact)
+		}
+	}
+}
+
+func TestConsequencesAdd(t *testing.T) {
+	tests := []struct {
+		terms      []string
+		want       string
+		accessions string
+		impact     string
+	}{
+		{[]string{}, "", "", ""},
+		{[]string{"missense_variant"}, "missense_variant", "SO_0001583", Impact_MODERATE},
+		{[]string{"intron_variant", "splice_donor_variant"}, "splice_donor_variant&intron_variant", "SO_0001575&SO_0001627", Impact_HIGH},
+		{[]string{"synonymous_variant", "splice_region_variant", "synonymous_variant"}, "splice_region_variant&synonymous_variant", "SO_0001630&SO_0001819", Impact_LOW},
+		{[]string{"3_prime_UTR_variant", "stop_lost", "frameshift_variant"}, "frameshift_variant&stop_lost&3_prime_UTR_variant", "SO_0001589&SO_0001578&SO_0001624", Impact_HIGH},
+		{[]string{"unknown_variant"}, "", "", ""},
+	}
+	for _, test := range tests {
+		csqs := make(Consequences, 0)
+		for _, term := range test.terms {
+			csqs = csqs.add(term)
+		}
+		if csqs.Terms() != test.want || csqs.Accessions() != test.accessions || csqs.Impact() != test.impact {
+			t.Errorf("%v: got %s %s %s, want %s %s %s", test.terms, csqs.Terms(), csqs.Accessions(), csqs.Impact(), test.want, test.accessions, test.impact)
+		}
+	}
+}
+
+func TestStopRetained(t *testing.T) {
+	// 转录本chr1:101-130，单个exon，编码序列为ATG AAA TAA，正链CDS 101-109，负链CDS 122-130
+	lines := map[string]string{
+		"+": "0\tNM_STOP\tchr1\t+\t100\t130\t100\t109\t1\t100,\t130,\t0\tGENE\tcmpl\tcmpl\t0,",
+		"-": "0\tNM_STOP\tchr1\t-\t100\t130\t121\t130\t1\t100,\t130,\t0\tGENE\tcmpl\tcmpl\t0,",
+	}
+	seqs := map[string]string{
+		"+": "ATGAAATAA" + "CCCCCCCCCCCCCCCCCCCCC",
+		"-": "CCCCCCCCCCCCCCCCCCCCC" + "TTATTTCAT",
+	}
+	tests := []struct {
+		name     string
+		strand   string
+		snv      pkg.AnnoVariant
+		event    string
+		aaChange string
+		terms    string
+	}{
+		{"plus TAA>TAG", "+", pkg.AnnoVariant{Start: 109, End: 109, Ref: "A", Alt: "G"}, "stopretained", "p.*3*", "stop_retained_variant"},
+		{"plus TAA>TGA", "+", pkg.AnnoVariant{Start: 108, End: 108, Ref: "A", Alt: "G"}, "stopretained", "p.*3*", "stop_retained_variant"},
+		{"plus TAA>CAA", "+", pkg.AnnoVariant{Start: 107, End: 107, Ref: "T", Alt: "C"}, "stoploss", "p.*3Qext*?", "stop_lost"},
+		{"plus AAA>AAG", "+", pkg.AnnoVariant{Start: 106, End: 106, Ref: "A", Alt: "G"}, "synonymous", "p.K2K", "synonymous_variant"},
+		{"plus sub TAA>TGA", "+", pkg.AnnoVariant{Start: 107, End: 108, Ref: "TA", Alt: "TG"}, "stopretained", "", "stop_retained_variant"},
+		{"plus sub TAA>TGG", "+", pkg.AnnoVariant{Start: 108, End: 109, Ref: "AA", Alt: "GG"}, "stoploss", "p.*3W", "stop_lost"},
+		{"plus sub AAATAA>AAGTAG", "+", pkg.AnnoVariant{Start: 106, End: 109, Ref: "ATAA", Alt: "GTAG"}, "stopretained", "", "stop_retained_variant"},
+		{"plus sub AAA>AGG", "+", pkg.AnnoVariant{Start: 105, End: 106, Ref: "AA", Alt: "GG"}, "missense", "p.K2R", "missense_variant"},
+		{"minus TAA>TAG", "-", pkg.AnnoVariant{Start: 122, End: 122, Ref: "T", Alt: "C"}, "stopretained", "p.*3*", "stop_retained_variant"},
+		{"minus AAA>AAG", "-", pkg.AnnoVariant{Start: 125, End: 125, Ref: "T", Alt: "C"}, "synonymous", "p.K2K", "synonymous_variant"},
+		{"minus sub TAA>TAG", "-", pkg.AnnoVariant{Start: 122, End: 123, Ref: "TT", Alt: "CT"}, "stopretained", "", "stop_retained_variant"},
+		{"minus sub AAA>AAG", "-", pkg.AnnoVariant{Start: 125, End: 126, Ref: "TT", Alt: "CT"}, "synonymous", "", "synonymous_variant"},
+	}
+	options := DefaultOptions()
+	options.AAShort = true
+	for _, test := range tests {
+		trans, err := pkg.NewTranscript(lines[test.strand])
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.Regions = pkg.NewRegionsWithSeq(trans, seqs[test.strand])
+		test.snv.Chrom = "chr1"
+		var transAnno TransAnno
+		if len(test.snv.Ref) > 1 {
+			transAnno = options.AnnoSub(test.snv, trans)
+		} else {
+			transAnno = options.AnnoSnp(test.snv, trans)
+		}
+		terms := options.NewConsequences(transAnno, test.snv, trans).Terms()
+		if transAnno.Event != test.event || transAnno.AAChange != test.aaChange || terms != test.terms {
+			t.Errorf("%s: got %s %s %s, want %s %s %s", test.name, transAnno.Event, transAnno.AAChange, terms, test.event, test.aaChange, test.terms)
+		}
+	}
+}
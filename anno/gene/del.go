@@ -35,7 +35,7 @@ func getCDSPosOfNAchange(trans pkg.Transcript, cdsLen, pos, cLen int, region pkg
 	return "", -1
 }
 
-func setDelAAChange(transAnno TransAnno, trans pkg.Transcript, cstart, cend int) TransAnno {
+func (this Options) setDelAAChange(transAnno TransAnno, trans pkg.Transcript, cstart, cend int) TransAnno {
 	cdna := trans.CDNA()
 	ncdna := pkg.Delete(cdna, cstart, cend)
 	if trans.Strand == "-" {
@@ -60,13 +60,13 @@ func setDelAAChange(transAnno TransAnno, trans pkg.Transcript, cstart, cend int)
 		transAnno.Event = "del_inframe"
 		if len(aa2) == 0 {
 			if len(aa1) == 1 {
-				transAnno.AAChange = fmt.Sprintf("p.%s%ddel", pkg.AAName(aa1, AA_SHORT), start)
+				transAnno.AAChange = fmt.Sprintf("p.%s%ddel", pkg.AAName(aa1, this.AAShort), start)
 			} else {
 				transAnno.AAChange = fmt.Sprintf(
 					"p.%s%d_%s%ddel",
-					pkg.AAName(aa1[0], AA_SHORT),
+					pkg.AAName(aa1[0], this.AAShort),
 					start,
-					pkg.AAName(aa1[len(aa1)-1], AA_SHORT),
+					pkg.AAName(aa1[len(aa1)-1], this.AAShort),
 					end1,
 				)
 			}
@@ -77,25 +77,25 @@ func setDelAAChange(transAnno TransAnno, trans pkg.Transcript, cstart, cend int)
 
 		} else {
 			if len(aa1) == 1 {
-				transAnno.AAChange = fmt.Sprintf("p.%s%ddelins%s", pkg.AAName(aa1, AA_SHORT), start, pkg.AAName(aa2, AA_SHORT))
+				transAnno.AAChange = fmt.Sprintf("p.%s%ddelins%s", pkg.AAName(aa1, this.AAShort), start, pkg.AAName(aa2, this.AAShort))
 			} else {
 				transAnno.AAChange = fmt.Sprintf(
 					"p.%s%d_%s%ddelins%s",
-					pkg.AAName(aa1[0], AA_SHORT),
+					pkg.AAName(aa1[0], this.AAShort),
 					start,
-					pkg.AAName(aa1[len(aa1)-1], AA_SHORT),
+					pkg.AAName(aa1[len(aa1)-1], this.AAShort),
 					end1,
-					pkg.AAName(aa2, AA_SHORT))
+					pkg.AAName(aa2, this.AAShort))
 			}
 		}
 	} else {
 		if start < len(protein) {
 			transAnno.Event = "del_frameshift"
 			if len(aa2) == 0 {
-				transAnno.AAChange = fmt.Sprintf("p.%s%dfs", pkg.AAName(aa1[0], AA_SHORT), start)
+				transAnno.AAChange = fmt.Sprintf("p.%s%dfs", pkg.AAName(aa1[0], this.AAShort), start)
 			} else {
 				if aa2[0] == '*' {
-					transAnno.AAChange = fmt.Sprintf("p.%s%d*", pkg.AAName(aa1[0], AA_SHORT), start)
+					transAnno.AAChange = fmt.Sprintf("p.%s%d*", pkg.AAName(aa1[0], this.AAShort), start)
 				} else {
 					var fs string
 					fsi := strings.IndexByte(nprotein[start-1:], '*')
@@ -106,7 +106,7 @@ func setDelAAChange(transAnno TransAnno, trans pkg.Transcript, cstart, cend int)
 							fs = fmt.Sprintf("%d", fsi+1)
 						}
 					}
-					transAnno.AAChange = fmt.Sprintf("p.%s%d%sfs*%s", pkg.AAName(aa1[0], AA_SHORT), start, pkg.AAName(aa2[0], AA_SHORT), fs)
+					transAnno.AAChange = fmt.Sprintf("p.%s%d%sfs*%s", pkg.AAName(aa1[0], this.AAShort), start, pkg.AAName(aa2[0], this.AAShort), fs)
 				}
 			}
 		}
@@ -123,7 +123,7 @@ func setDelAAChange(transAnno TransAnno, trans pkg.Transcript, cstart, cend int)
 	return transAnno
 }
 
-func AnnoDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+func (this Options) AnnoDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	// cStart, cEnd, region1, region2, isExonSplicing := getDelCLen(trans, snv)
 	utrLen1, utrLen2 := trans.ULen()
 	cdsLen := trans.CLen()
@@ -133,7 +133,7 @@ func AnnoDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	utrPosOfNAchange2 := getUTRPosOfNAchange(trans, utrLen1, utrLen2, snv.End, uLen2, region2)
 	cdsPosOfNAchange1, dist1 := getCDSPosOfNAchange(trans, cdsLen, snv.Start, cLen1, region1)
 	cdsPosOfNAchange2, dist2 := getCDSPosOfNAchange(trans, cdsLen, snv.End, cLen2, region2)
-	transAnno := NewTransAnno(trans, region1, region2)
+	transAnno := this.NewTransAnno(trans, region1, region2)
 	if !region1.Equal(region2) {
 		if region2.Start-region1.End > 1 {
 			transAnno.Region = "deletion"
@@ -212,7 +212,7 @@ func AnnoDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 						transAnno.NAChange = fmt.Sprintf("c.%s_%sdel", cdsPosOfNAchange2, cdsPosOfNAchange1)
 					}
 				}
-				if dist1 > 0 && dist2 > 0 && pkg.Min(dist1, dist2) <= 2 {
+				if dist1 > 0 && dist2 > 0 && pkg.Min(dist1, dist2) <= this.Splice.Site {
 					transAnno.Event = "splicing"
 					transAnno.Region = "splicing"
 				}
@@ -220,15 +220,15 @@ func AnnoDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 				cstart, cend := cLen1, cLen2
 				cstart += snv.Start - region1.Start + 1
 				cend += snv.End - region2.Start + 1
-				transAnno = setDelAAChange(transAnno, trans, cstart, cend)
+				transAnno = this.setDelAAChange(transAnno, trans, cstart, cend)
 			}
 		}
 	}
 	return transAnno
 }
 
-func AnnoUnkDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
-	transAnno := NewTransAnno(trans)
+func (this Options) AnnoUnkDel(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+	transAnno := this.NewTransAnno(trans)
 	transAnno.Region2 = "ncRNA"
 	if snv.Start < trans.TxStart {
 		if snv.End > trans.TxEnd {
@@ -6,7 +6,7 @@ import (
 	"strings"
 )
 
-func setInsAAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVariant, cPos int) TransAnno {
+func (this Options) setInsAAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVariant, cPos int) TransAnno {
 	// pos := cLen + snv.Start - region.Start + 1
 	cdna := trans.CDNA()
 	ncdna := pkg.Insert(cdna, cPos, snv.Alt)
@@ -69,9 +69,9 @@ func setInsAAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVaria
 					} else {
 						transAnno.AAChange = fmt.Sprintf(
 							"p.%s%d_%s%ddup",
-							pkg.AAName(protein[start-len(unit)-1], AA_SHORT),
+							pkg.AAName(protein[start-len(unit)-1], this.AAShort),
 							start-len(unit),
-							pkg.AAName(protein[start-2], AA_SHORT),
+							pkg.AAName(protein[start-2], this.AAShort),
 							start-1,
 						)
 					}
@@ -83,50 +83,50 @@ func setInsAAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVaria
 					if start == 1 {
 						transAnno.AAChange = fmt.Sprintf(
 							"p.%s%d-1_%s%dins%s",
-							pkg.AAName(protein[start-1], AA_SHORT),
+							pkg.AAName(protein[start-1], this.AAShort),
 							start,
-							pkg.AAName(protein[start-1], AA_SHORT),
+							pkg.AAName(protein[start-1], this.AAShort),
 							start,
-							pkg.AAName(aa2, AA_SHORT),
+							pkg.AAName(aa2, this.AAShort),
 						)
 					} else {
 						if start > len(protein) {
 							transAnno.AAChange = fmt.Sprintf(
 								"p.%s%d_%s%d+1ins%s",
-								pkg.AAName(protein[start-2], AA_SHORT),
+								pkg.AAName(protein[start-2], this.AAShort),
 								start-1,
-								pkg.AAName(protein[start-2], AA_SHORT),
+								pkg.AAName(protein[start-2], this.AAShort),
 								start-1,
-								pkg.AAName(aa2, AA_SHORT),
+								pkg.AAName(aa2, this.AAShort),
 							)
 						} else {
 							transAnno.AAChange = fmt.Sprintf(
 								"p.%s%d_%s%dins%s",
-								pkg.AAName(protein[start-2], AA_SHORT),
+								pkg.AAName(protein[start-2], this.AAShort),
 								start-1,
-								pkg.AAName(protein[start-1], AA_SHORT),
+								pkg.AAName(protein[start-1], this.AAShort),
 								start,
-								pkg.AAName(aa2, AA_SHORT),
+								pkg.AAName(aa2, this.AAShort),
 							)
 						}
 					}
 				}
 			} else if len(aa1) == 1 {
-				transAnno.AAChange = fmt.Sprintf("p.%s%ddelins%s", pkg.AAName(aa1, AA_SHORT), start-1, pkg.AAName(aa2, AA_SHORT))
+				transAnno.AAChange = fmt.Sprintf("p.%s%ddelins%s", pkg.AAName(aa1, this.AAShort), start-1, pkg.AAName(aa2, this.AAShort))
 			} else {
 				transAnno.AAChange = fmt.Sprintf(
 					"p.%s%d_%s%ddelins%s",
-					pkg.AAName(aa1[0], AA_SHORT),
+					pkg.AAName(aa1[0], this.AAShort),
 					start-1,
-					pkg.AAName(aa1[len(aa1)-1], AA_SHORT),
+					pkg.AAName(aa1[len(aa1)-1], this.AAShort),
 					end1-1,
-					pkg.AAName(aa2, AA_SHORT))
+					pkg.AAName(aa2, this.AAShort))
 			}
 		} else {
 			if start < len(protein) {
 				transAnno.Event = "ins_frameshift"
 				if aa2[0] == '*' {
-					transAnno.AAChange = fmt.Sprintf("p.%s%d*", pkg.AAName(aa1[0], AA_SHORT), start)
+					transAnno.AAChange = fmt.Sprintf("p.%s%d*", pkg.AAName(aa1[0], this.AAShort), start)
 				} else {
 					var fs string
 					fsi := strings.IndexByte(nprotein[start-1:], '*')
@@ -137,7 +137,7 @@ func setInsAAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVaria
 							fs = fmt.Sprintf("%d", fsi+1)
 						}
 					}
-					transAnno.AAChange = fmt.Sprintf("p.%s%d%sfs*%s", pkg.AAName(aa1[0], AA_SHORT), start, pkg.AAName(aa2[0], AA_SHORT), fs)
+					transAnno.AAChange = fmt.Sprintf("p.%s%d%sfs*%s", pkg.AAName(aa1[0], this.AAShort), start, pkg.AAName(aa2[0], this.AAShort), fs)
 				}
 			}
 		}
@@ -151,7 +151,29 @@ func setInsAAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVaria
 	return transAnno
 }
 
-func AnnoIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+// setInsNAChange 设置非编码序列中插入的核酸改变，插入序列与其5'端相邻序列相同时描述为dup
+func setInsNAChange(transAnno TransAnno, trans pkg.Transcript, snv pkg.AnnoVariant, pos1, pos2 string) TransAnno {
+	alt := snv.Alt
+	if trans.Strand == "-" {
+		alt = pkg.RevComp(alt)
+	}
+	if start, end, ok := trans.DupRange(snv); ok {
+		dupStart, dupEnd := getPosOfNAchange(trans, start), getPosOfNAchange(trans, end)
+		if trans.Strand == "-" {
+			dupStart, dupEnd = dupEnd, dupStart
+		}
+		if start == end {
+			transAnno.NAChange = fmt.Sprintf("c.%sdup%s", dupStart, alt)
+		} else {
+			transAnno.NAChange = fmt.Sprintf("c.%s_%sdup%s", dupStart, dupEnd, alt)
+		}
+		return transAnno
+	}
+	transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", pos1, pos2, alt)
+	return transAnno
+}
+
+func (this Options) AnnoIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	utrLen1, utrLen2 := trans.ULen()
 	cdsLen := trans.CLen()
 	region1, cLen1, uLen1 := trans.Region(snv.Start)
@@ -162,31 +184,31 @@ func AnnoIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	cdsPosOfNAchange2, dist2 := getCDSPosOfNAchange(trans, cdsLen, snv.Start+1, cLen2, region2)
 	var transAnno TransAnno
 	if trans.Strand == "+" {
-		transAnno = NewTransAnno(trans, region2)
+		transAnno = this.NewTransAnno(trans, region2)
 	} else {
-		transAnno = NewTransAnno(trans, region1)
+		transAnno = this.NewTransAnno(trans, region1)
 	}
 	if !region1.Equal(region2) {
 		if !region1.Exists() || !region2.Exists() || region2.End < trans.CdsStart || region1.Start > trans.CdsEnd {
 			if trans.Strand == "+" {
-				transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", utrPosOfNAchange1, utrPosOfNAchange2, snv.Alt)
+				transAnno = setInsNAChange(transAnno, trans, snv, utrPosOfNAchange1, utrPosOfNAchange2)
 			} else {
-				transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", utrPosOfNAchange2, utrPosOfNAchange1, pkg.RevComp(snv.Alt))
+				transAnno = setInsNAChange(transAnno, trans, snv, utrPosOfNAchange2, utrPosOfNAchange1)
 			}
 		} else if region1.Start >= trans.CdsStart && region2.End <= trans.CdsEnd {
 			if trans.Strand == "+" {
 				if region2.Type == pkg.RType_CDS {
-					transAnno = setInsAAChange(transAnno, trans, snv, cLen2)
+					transAnno = this.setInsAAChange(transAnno, trans, snv, cLen2)
 				} else {
-					transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", cdsPosOfNAchange1, cdsPosOfNAchange2, snv.Alt)
+					transAnno = setInsNAChange(transAnno, trans, snv, cdsPosOfNAchange1, cdsPosOfNAchange2)
 					transAnno.Event = "splicing"
 					transAnno.Region = "splicing"
 				}
 			} else {
 				if region1.Type == pkg.RType_CDS {
-					transAnno = setInsAAChange(transAnno, trans, snv, cLen2)
+					transAnno = this.setInsAAChange(transAnno, trans, snv, cLen2)
 				} else {
-					transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", cdsPosOfNAchange2, cdsPosOfNAchange1, pkg.RevComp(snv.Alt))
+					transAnno = setInsNAChange(transAnno, trans, snv, cdsPosOfNAchange2, cdsPosOfNAchange1)
 					transAnno.Event = "splicing"
 					transAnno.Region = "splicing"
 				}
@@ -195,35 +217,35 @@ func AnnoIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 		} else {
 			if trans.Strand == "+" {
 				if region1.End < trans.CdsStart {
-					transAnno = setInsAAChange(transAnno, trans, snv, cLen2)
+					transAnno = this.setInsAAChange(transAnno, trans, snv, cLen2)
 				} else {
-					transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", cdsPosOfNAchange1, utrPosOfNAchange2, snv.Alt)
+					transAnno = setInsNAChange(transAnno, trans, snv, cdsPosOfNAchange1, utrPosOfNAchange2)
 				}
 			} else {
 				if region1.End < trans.CdsEnd {
-					transAnno = setInsAAChange(transAnno, trans, snv, cLen2)
+					transAnno = this.setInsAAChange(transAnno, trans, snv, cLen2)
 				} else {
-					transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", cdsPosOfNAchange2, utrPosOfNAchange1, pkg.RevComp(snv.Alt))
+					transAnno = setInsNAChange(transAnno, trans, snv, cdsPosOfNAchange2, utrPosOfNAchange1)
 				}
 			}
 		}
 	} else {
 		if region1.End < trans.CdsStart || region1.Start > trans.CdsEnd {
 			if trans.Strand == "+" {
-				transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", utrPosOfNAchange1, utrPosOfNAchange2, snv.Alt)
+				transAnno = setInsNAChange(transAnno, trans, snv, utrPosOfNAchange1, utrPosOfNAchange2)
 			} else {
-				transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", utrPosOfNAchange2, utrPosOfNAchange1, pkg.RevComp(snv.Alt))
+				transAnno = setInsNAChange(transAnno, trans, snv, utrPosOfNAchange2, utrPosOfNAchange1)
 			}
 		} else {
 			if region1.Type == pkg.RType_CDS {
-				transAnno = setInsAAChange(transAnno, trans, snv, cLen1+snv.Start-region1.Start+1)
+				transAnno = this.setInsAAChange(transAnno, trans, snv, cLen1+snv.Start-region1.Start+1)
 			} else {
 				if trans.Strand == "+" {
-					transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", cdsPosOfNAchange1, cdsPosOfNAchange2, snv.Alt)
+					transAnno = setInsNAChange(transAnno, trans, snv, cdsPosOfNAchange1, cdsPosOfNAchange2)
 				} else {
-					transAnno.NAChange = fmt.Sprintf("c.%s_%sins%s", cdsPosOfNAchange2, cdsPosOfNAchange1, pkg.RevComp(snv.Alt))
+					transAnno = setInsNAChange(transAnno, trans, snv, cdsPosOfNAchange2, cdsPosOfNAchange1)
 				}
-				if pkg.Min(dist1, dist2) <= 2 {
+				if pkg.Min(dist1, dist2) <= this.Splice.Site {
 					transAnno.Event = "splicing"
 					transAnno.Region = "splicing"
 				}
@@ -233,8 +255,8 @@ func AnnoIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	return transAnno
 }
 
-func AnnoUnkIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
-	transAnno := NewTransAnno(trans)
+func (this Options) AnnoUnkIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+	transAnno := this.NewTransAnno(trans)
 	transAnno.Region2 = "ncRNA"
 	pos := snv.Start - trans.TxStart + 1
 	dna := trans.DNA()
@@ -251,14 +273,15 @@ func AnnoUnkIns(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	} else if start-1 == len(dna) {
 		// 在CDNA最后碱基后插入，不影响整条蛋白序列
 		transAnno.NAChange = fmt.Sprintf("n.%d_%d+1ins%s", len(dna), len(dna), alt)
-	} else {
-		unit := pkg.DupUnit(alt)
-		if start > len(unit) && unit == dna[start-len(unit)-1:start-1] {
-			// 比较重复单元和其之前的碱基
-			transAnno.NAChange = fmt.Sprintf("n.%ddup%s", start-1, unit)
+	} else if start > len(alt) && alt == dna[start-len(alt)-1:start-1] {
+		// 插入序列与其之前的碱基相同时描述为dup
+		if len(alt) == 1 {
+			transAnno.NAChange = fmt.Sprintf("n.%ddup%s", start-1, alt)
 		} else {
-			transAnno.NAChange = fmt.Sprintf("n.%d_%dins%s", start-1, start, alt)
+			transAnno.NAChange = fmt.Sprintf("n.%d_%ddup%s", start-len(alt), start-1, alt)
 		}
+	} else {
+		transAnno.NAChange = fmt.Sprintf("n.%d_%dins%s", start-1, start, alt)
 	}
 	return transAnno
 }
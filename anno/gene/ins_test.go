@@ -0,0 +1,41 @@
+package gene
+
+import (
+	"open-anno/pkg"
+	"testing"
+)
+
+func TestAnnoUnkIns(t *testing.T) {
+	// 非编码转录本chr1:101-130，单个exon
+	seq := "ACGTAAACAG" + "ATATATGCTA" + "CCCCCCCCCC"
+	tests := []struct {
+		name     string
+		strand   string
+		snv      pkg.AnnoVariant
+		naChange string
+	}{
+		{"plus dup of one base", "+", pkg.AnnoVariant{Start: 104, End: 104, Ref: "-", Alt: "A"}, "n.7dupA"},
+		{"plus dup of repeat unit", "+", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "AT"}, "n.15_16dupAT"},
+		{"plus dup of two repeat units", "+", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "ATAT"}, "n.13_16dupATAT"},
+		{"plus dup of whole repeat", "+", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "ATATAT"}, "n.11_16dupATATAT"},
+		{"plus repeat longer than reference", "+", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "ATATATAT"}, "n.16_17insATATATAT"},
+		{"plus insertion", "+", pkg.AnnoVariant{Start: 120, End: 120, Ref: "-", Alt: "GGT"}, "n.20_21insGGT"},
+		{"minus dup of repeat unit", "-", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "AT"}, "n.19_20dupAT"},
+		{"minus dup of two repeat units", "-", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "ATAT"}, "n.17_20dupATAT"},
+		{"minus repeat longer than reference", "-", pkg.AnnoVariant{Start: 110, End: 110, Ref: "-", Alt: "ATATATAT"}, "n.20_21insATATATAT"},
+	}
+	options := DefaultOptions()
+	for _, test := range tests {
+		line := "0\tNR_INS\tchr1\t" + test.strand + "\t100\t130\t130\t130\t1\t100,\t130,\t0\tGENE\tunk\tunk\t-1,"
+		trans, err := pkg.NewTranscript(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.Regions = pkg.NewRegionsWithSeq(trans, seq)
+		test.snv.Chrom = "chr1"
+		transAnno := options.AnnoSnvTrans(test.snv, pkg.VType_INS, trans)
+		if transAnno.NAChange != test.naChange {
+			t.Errorf("%s: got %s, want %s", test.name, transAnno.NAChange, test.naChange)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gene
+
+import (
+	"fmt"
+	"open-anno/pkg"
+	"strings"
+
+	"github.com/brentp/bix"
+)
+
+// AnnoUpDownStream 注释位于转录本上游或下游的变异
+func (this Options) AnnoUpDownStream(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+	transAnno := this.NewTransAnno(trans)
+	var upstream bool
+	if snv.End < trans.TxStart {
+		transAnno.Distance = trans.TxStart - snv.End
+		upstream = trans.Strand == "+"
+	} else {
+		transAnno.Distance = snv.Start - trans.TxEnd
+		upstream = trans.Strand == "-"
+	}
+	if upstream {
+		transAnno.Region = "upstream"
+		transAnno.Consequences = Consequences{}.add("upstream_gene_variant")
+	} else {
+		transAnno.Region = "downstream"
+		transAnno.Consequences = Consequences{}.add("downstream_gene_variant")
+	}
+	transAnno.Region2 = transAnno.Region
+	return transAnno
+}
+
+// NewIntergenicAnno 基因间区变异的注释结果
+func NewIntergenicAnno() TransAnno {
+	return TransAnno{Region: "intergenic", Consequences: Consequences{}.add("intergenic_variant")}
+}
+
+// NearestGene 基因间区变异一侧最近的基因
+type NearestGene struct {
+	Gene     string `json:"gene"`
+	GeneID   string `json:"gene_id"`
+	Distance int    `json:"distance"`
+}
+
+// NearestGenes 基因间区变异左右两侧最近的基因
+type NearestGenes [2]NearestGene
+
+// String FORMAT=Gene:GeneID:Distance，某侧无基因时为 .
+func (this NearestGenes) String() string {
+	texts := make([]string, 2)
+	for i, nearest := range this {
+		texts[i] = "."
+		if nearest.Gene != "" {
+			texts[i] = fmt.Sprintf("%s:%s:%d", nearest.Gene, nearest.GeneID, nearest.Distance)
+		}
+	}
+	return strings.Join(texts, ",")
+}
+
+// searchNearestGene 在 [start, end] 区间内查找距离pos最近的转录本, left 表示在pos左侧查找
+func (this Options) searchNearestGene(chrom string, start, end, pos int, left bool, tbx *bix.Bix) (NearestGene, error) {
+	var nearest NearestGene
+	query, err := tbx.Query(pkg.NewPosition(chrom, start, end))
+	if err != nil {
+		return nearest, err
+	}
+	defer query.Close()
+	for v, e := query.Next(); e == nil; v, e = query.Next() {
+		trans, err := pkg.NewTranscript(fmt.Sprintf("%s", v))
+		if err != nil {
+			return nearest, err
+		}
+		var dist int
+		if left && trans.TxEnd < pos {
+			dist = pos - trans.TxEnd
+		} else if !left && trans.TxStart > pos {
+			dist = trans.TxStart - pos
+		} else {
+			continue
+		}
+		if nearest.Gene == "" || dist < nearest.Distance {
+			trans.SetGeneID(this.GeneSymbols)
+			nearest = NearestGene{Gene: trans.Gene, GeneID: trans.GeneID, Distance: dist}
+		}
+	}
+	return nearest, nil
+}
+
+// AnnoIntergenic 查找基因间区变异左右两侧最近的基因，查找窗口从上下游长度开始逐步加倍
+func (this Options) AnnoIntergenic(snv pkg.AnnoVariant, tbx *bix.Bix) (NearestGenes, error) {
+	var nearestGenes NearestGenes
+	var err error
+	maxWindow := 1 << 28
+	for window := pkg.Max(this.UpDownStream, 1000) * 2; window <= maxWindow; window *= 2 {
+		if nearestGenes[0].Gene == "" {
+			nearestGenes[0], err = this.searchNearestGene(snv.Chrom, snv.Start-window, snv.Start-1, snv.Start, true, tbx)
+			if err != nil {
+				return nearestGenes, err
+			}
+		}
+		if nearestGenes[1].Gene == "" {
+			nearestGenes[1], err = this.searchNearestGene(snv.Chrom, snv.End+1, snv.End+window, snv.End, false, tbx)
+			if err != nil {
+				return nearestGenes, err
+			}
+		}
+		if (nearestGenes[0].Gene != "" || snv.Start-window <= 1) && nearestGenes[1].Gene != "" {
+			break
+		}
+	}
+	return nearestGenes, nil
+}
@@ -0,0 +1,47 @@
+package gene
+
+import (
+	"fmt"
+	"open-anno/pkg"
+)
+
+// ncRegionName 非编码转录本区域名称，exon使用外显子编号，如 exon2, intron1
+func ncRegionName(region pkg.Region) string {
+	if region.Type == pkg.RType_INTRON {
+		return region.Name(false)
+	}
+	return region.Exon
+}
+
+// ncExonic 变异是否与非编码转录本的exon重叠
+func ncExonic(snv pkg.AnnoVariant, trans pkg.Transcript) bool {
+	for _, region := range trans.Regions {
+		if region.Type != pkg.RType_INTRON && region.Start <= snv.End && region.End >= snv.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// setNcRNAAnno 设置非编码转录本的Region及Region2，Region沿用ncRNA，Region2为exon/intron编号，如 exon2, intron1
+func setNcRNAAnno(transAnno *TransAnno, snv pkg.AnnoVariant, trans pkg.Transcript) {
+	transAnno.Region = "ncRNA"
+	regions := make(pkg.Regions, 0)
+	for _, pos := range []int{snv.Start, snv.End} {
+		region, _, _ := trans.Region(pos)
+		if region.Exists() {
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		return
+	}
+	if trans.Strand == "-" {
+		regions[0], regions[len(regions)-1] = regions[len(regions)-1], regions[0]
+	}
+	name1, name2 := ncRegionName(regions[0]), ncRegionName(regions[len(regions)-1])
+	transAnno.Region2 = name1
+	if name1 != name2 {
+		transAnno.Region2 = fmt.Sprintf("%s_%s", name1, name2)
+	}
+}
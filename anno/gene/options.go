@@ -0,0 +1,27 @@
+package gene
+
+import "open-anno/pkg"
+
+// Options 基因注释参数，每个注释器持有独立的参数，不依赖全局变量
+type Options struct {
+	AAShort      bool                // 氨基酸使用单字母缩写
+	ExonRegion   bool                // Region2中CDS使用exon编号
+	UpDownStream int                 // 转录本上下游长度
+	TransMode    string              // 转录本选择模式，all, rep, mane 或 pick
+	Build        string              // 基因组版本，GRCh37 或 GRCh38
+	Splice       pkg.SpliceLens      // 剪接区域长度
+	GeneSymbols  pkg.GeneSymbols     // 基因Symbol对应的EntrezID
+	RepTrans     pkg.RepTranscripts  // 代表性转录本
+	Accessions   pkg.TransAccessions // 转录本带版本号的登录号及蛋白登录号
+	MaxEntScan   *pkg.MaxEntScan     // MaxEntScan模型，为nil时不进行剪接位点打分
+}
+
+// DefaultOptions 默认基因注释参数
+func DefaultOptions() Options {
+	return Options{
+		UpDownStream: 5000,
+		TransMode:    TransMode_ALL,
+		Build:        pkg.Build_GRCh38,
+		Splice:       pkg.DefaultSpliceLens,
+	}
+}
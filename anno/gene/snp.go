@@ -83,9 +83,20 @@ func getUTRPosOfNAchange(trans pkg.Transcript, utrLen1, utrLen2, pos, uLen int,
 	return ""
 }
 
-func AnnoSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+// getPosOfNAchange 基因组位置在转录本上的c.位置
+func getPosOfNAchange(trans pkg.Transcript, pos int) string {
+	region, cLen, uLen := trans.Region(pos)
+	if pos < trans.CdsStart || pos > trans.CdsEnd {
+		utrLen1, utrLen2 := trans.ULen()
+		return getUTRPosOfNAchange(trans, utrLen1, utrLen2, pos, uLen, region)
+	}
+	cdsPos, _ := getCDSPosOfNAchange(trans, trans.CLen(), pos, cLen, region)
+	return cdsPos
+}
+
+func (this Options) AnnoSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	region, cLen, uLen := trans.Region(snv.Start)
-	transAnno := NewTransAnno(trans, region)
+	transAnno := this.NewTransAnno(trans, region)
 	if snv.Start < trans.CdsStart || snv.Start > trans.CdsEnd {
 		utrLen1, utrLen2 := trans.ULen()
 		utrPosOfNAchange := getUTRPosOfNAchange(trans, utrLen1, utrLen2, snv.Start, uLen, region)
@@ -112,7 +123,7 @@ func AnnoSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 					transAnno.NAChange = fmt.Sprintf("c.%d+%d%s>%s", cdsLen-cLen, dist2, pkg.RevComp(snv.Ref), pkg.RevComp(snv.Alt))
 				}
 			}
-			if pkg.Min(dist1, dist2) <= 2 {
+			if pkg.Min(dist1, dist2) <= this.Splice.Site {
 				transAnno.Event = "splicing"
 				transAnno.Region = "splicing"
 			}
@@ -134,7 +145,9 @@ func AnnoSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 				pstart = ((cstart - 1) / 3) + 1
 			}
 			aa1, aa2 := protein[pstart-1], nprotein[pstart-1]
-			if aa1 == aa2 {
+			if aa1 == aa2 && aa1 == '*' {
+				transAnno.Event = "stopretained"
+			} else if aa1 == aa2 {
 				transAnno.Event = "synonymous"
 			} else {
 				if aa1 == 'M' && pstart == 1 {
@@ -147,10 +160,10 @@ func AnnoSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 					transAnno.Event = "missense"
 				}
 			}
-			if aa1 == '*' {
-				transAnno.AAChange = fmt.Sprintf("p.%s%d%sext*?", pkg.AAName(aa1, AA_SHORT), pstart, pkg.AAName(aa2, AA_SHORT))
+			if aa1 == '*' && aa2 != '*' {
+				transAnno.AAChange = fmt.Sprintf("p.%s%d%sext*?", pkg.AAName(aa1, this.AAShort), pstart, pkg.AAName(aa2, this.AAShort))
 			} else {
-				transAnno.AAChange = fmt.Sprintf("p.%s%d%s", pkg.AAName(aa1, AA_SHORT), pstart, pkg.AAName(aa2, AA_SHORT))
+				transAnno.AAChange = fmt.Sprintf("p.%s%d%s", pkg.AAName(aa1, this.AAShort), pstart, pkg.AAName(aa2, this.AAShort))
 			}
 
 		}
@@ -158,8 +171,8 @@ func AnnoSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	return transAnno
 }
 
-func AnnoUnkSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
-	transAnno := NewTransAnno(trans)
+func (this Options) AnnoUnkSnp(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+	transAnno := this.NewTransAnno(trans)
 	transAnno.Region2 = "ncRNA"
 	pos := snv.Start - trans.TxStart + 1
 	dna := trans.DNA()
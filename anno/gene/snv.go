@@ -10,17 +10,47 @@ import (
 	"github.com/brentp/faidx"
 )
 
-var AA_SHORT = false
-
 type TransAnno struct {
-	Gene       string `json:"gene"`
-	GeneID     string `json:"gene_id"`
-	Transcript string `json:"transcript"`
-	Region     string `json:"region"` // such as: exonic, intronic
-	NAChange   string `json:"na_change"`
-	AAChange   string `json:"aa_change"`
-	Event      string `json:"event"`
-	Region2    string `json:"region2"` // such as: CDS1, exon1, intron1
+	Gene         string            `json:"gene"`
+	GeneID       string            `json:"gene_id"`
+	Transcript   string            `json:"transcript"`
+	Protein      string            `json:"protein"`
+	Biotype      string            `json:"biotype"` // such as: protein_coding, lncRNA, miRNA, processed_pseudogene
+	Region       string            `json:"region"`  // such as: exonic, intronic
+	NAChange     string            `json:"na_change"`
+	AAChange     string            `json:"aa_change"`
+	Event        string            `json:"event"`
+	Region2      string            `json:"region2"` // such as: CDS1, exon1, intron1
+	Exon         string            `json:"exon"`    // such as: 3/12
+	Intron       string            `json:"intron"`  // such as: 2/11
+	Consequences Consequences      `json:"consequences"`
+	MaxEntScores []pkg.MaxEntScore `json:"maxentscan"`
+	Canonical    string            `json:"canonical"`      // 代表性转录本来源，如 MANE_Select
+	Distance     int               `json:"distance"`       // 上下游变异距转录本的距离
+	HGVSOffset   int               `json:"hgvs_offset"`    // 3'原则下Indel向3'端移动的碱基数
+	ShiftCross   bool              `json:"shift_boundary"` // 是否因跨越exon/intron边界而停止移动
+}
+
+// MaxEntScan 剪接位点MaxEntScan打分，FORMAT=Transcript:RefScore:AltScore:Delta
+func (this TransAnno) MaxEntScan(site string) string {
+	for _, score := range this.MaxEntScores {
+		if score.Site == site {
+			return fmt.Sprintf("%s:%.2f:%.2f:%.2f", this.Transcript, score.Ref, score.Alt, score.Diff())
+		}
+	}
+	return ""
+}
+
+// Shift 3'原则移动信息，FORMAT=Transcript:Offset[:boundary]
+func (this TransAnno) Shift() string {
+	if this.HGVSOffset == 0 && !this.ShiftCross {
+		return ""
+	}
+	shift := fmt.Sprintf("%s:%d", this.Transcript, this.HGVSOffset)
+	if this.ShiftCross {
+		shift += ":boundary"
+	}
+	return shift
 }
 
 func (this TransAnno) Detail() string {
@@ -30,15 +60,36 @@ func (this TransAnno) Detail() string {
 		if this.AAChange != "" {
 			detail += fmt.Sprintf(":%s", this.AAChange)
 		}
+	} else if this.Distance > 0 {
+		detail = fmt.Sprintf("%s:%s:%s:dist=%d", this.Gene, this.Transcript, this.Region2, this.Distance)
 	}
 	return detail
 }
 
-func NewTransAnno(trans pkg.Transcript, regions ...pkg.Region) TransAnno {
+// HGVSc 转录本HGVS表达式，如 NM_000546.6:c.215C>G
+func (this TransAnno) HGVSc() string {
+	if this.NAChange == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", this.Transcript, this.NAChange)
+}
+
+// HGVSp 蛋白HGVS表达式，如 NP_000537.3:p.Pro72Arg
+func (this TransAnno) HGVSp() string {
+	if this.AAChange == "" || this.Protein == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", this.Protein, this.AAChange)
+}
+
+func (this Options) NewTransAnno(trans pkg.Transcript, regions ...pkg.Region) TransAnno {
 	transAnno := TransAnno{
 		Gene:       trans.Gene,
 		GeneID:     trans.GeneID,
-		Transcript: trans.Name,
+		Transcript: this.Accessions.Transcript(trans),
+		Protein:    this.Accessions.Protein(trans),
+		Biotype:    trans.TransBiotype(),
+		Canonical:  this.RepTrans.Source(trans),
 	}
 	nregions := make(pkg.Regions, 0)
 	for _, region := range regions {
@@ -54,24 +105,24 @@ func NewTransAnno(trans pkg.Transcript, regions ...pkg.Region) TransAnno {
 	if len(nregions) > 0 {
 		region1, region2 := nregions[0], nregions[len(nregions)-1]
 		transAnno.Region2 = "."
-		if region1.Name() != "" {
-			transAnno.Region2 = region1.Name()
-			if region2.Name() != "" && region1.Name() != region2.Name() {
-				transAnno.Region2 = fmt.Sprintf("%s_%s", region1.Name(), region2.Name())
+		if region1.Name(this.ExonRegion) != "" {
+			transAnno.Region2 = region1.Name(this.ExonRegion)
+			if region2.Name(this.ExonRegion) != "" && region1.Name(this.ExonRegion) != region2.Name(this.ExonRegion) {
+				transAnno.Region2 = fmt.Sprintf("%s_%s", region1.Name(this.ExonRegion), region2.Name(this.ExonRegion))
 			}
 		} else {
-			if region2.Name() != "" {
-				transAnno.Region2 = region2.Name()
+			if region2.Name(this.ExonRegion) != "" {
+				transAnno.Region2 = region2.Name(this.ExonRegion)
 			}
 		}
 		if region1.Type == pkg.RType_CDS || region2.Type == pkg.RType_CDS {
 			transAnno.Region = "exonic"
 		} else {
 			if region1.Type == pkg.RType_UTR {
-				transAnno.Region = region1.Name()
+				transAnno.Region = region1.Name(this.ExonRegion)
 			} else {
 				if region2.Type == pkg.RType_UTR {
-					transAnno.Region = region2.Name()
+					transAnno.Region = region2.Name(this.ExonRegion)
 				} else {
 					transAnno.Region = "intronic"
 				}
@@ -81,84 +132,184 @@ func NewTransAnno(trans pkg.Transcript, regions ...pkg.Region) TransAnno {
 	return transAnno
 }
 
-func AnnoSnv(snv *pkg.SNV, tbx *bix.Bix, genome *faidx.Faidx) (map[string]any, error) {
+// AnnoSnvTrans 注释SNV在单个转录本上的功能
+func (this Options) AnnoSnvTrans(annoVar pkg.AnnoVariant, vtype string, trans pkg.Transcript) TransAnno {
+	transVar, offset, crossed := trans.ShiftVariant(annoVar)
+	var transAnno TransAnno
+	if trans.IsUnk() {
+		if vtype == pkg.VType_SNP {
+			transAnno = this.AnnoUnkSnp(transVar, trans)
+		} else if vtype == pkg.VType_INS {
+			transAnno = this.AnnoUnkIns(transVar, trans)
+		} else if vtype == pkg.VType_DEL {
+			transAnno = this.AnnoUnkDel(transVar, trans)
+		} else {
+			transAnno = this.AnnoUnkSub(transVar, trans)
+		}
+		setNcRNAAnno(&transAnno, annoVar, trans)
+	} else {
+		if vtype == pkg.VType_SNP {
+			transAnno = this.AnnoSnp(transVar, trans)
+		} else if vtype == pkg.VType_INS {
+			transAnno = this.AnnoIns(transVar, trans)
+		} else if vtype == pkg.VType_DEL {
+			transAnno = this.AnnoDel(transVar, trans)
+		} else {
+			transAnno = this.AnnoSub(transVar, trans)
+		}
+	}
+	transAnno.HGVSOffset, transAnno.ShiftCross = offset, crossed
+	transAnno.Exon, transAnno.Intron = trans.ExonIntron(transVar)
+	transAnno.Consequences = this.NewConsequences(transAnno, transVar, trans)
+	transAnno.MaxEntScores = trans.MaxEntScores(annoVar, this.MaxEntScan)
+	return transAnno
+}
+
+// addGeneAnno 将转录本注释结果合并到基因注释结果中
+func addGeneAnno(geneAnnos map[string]map[string][]string, transAnno TransAnno) {
+	geneAnno, ok := geneAnnos[transAnno.Gene]
+	if !ok {
+		geneAnno = map[string][]string{"gene": {transAnno.Gene}, "gene_id": {transAnno.GeneID}, "region": {}, "event": {}, "detail": {}, "HGVSc": {}, "HGVSp": {}, "consequence": {}, "maxent_donor": {}, "maxent_acceptor": {}, "hgvs_offset": {}, "canonical": {}, "biotype": {}, "exon": {}, "intron": {}}
+	}
+	region, event, detail, shift := transAnno.Region, transAnno.Event, transAnno.Detail(), transAnno.Shift()
+	if region != "" && region != "." && pkg.FindArr(geneAnno["region"], region) < 0 {
+		geneAnno["region"] = append(geneAnno["region"], region)
+	}
+	if event != "" && event != "." && pkg.FindArr(geneAnno["event"], event) < 0 {
+		geneAnno["event"] = append(geneAnno["event"], event)
+	}
+	if detail != "" && detail != "." && pkg.FindArr(geneAnno["detail"], detail) < 0 {
+		geneAnno["detail"] = append(geneAnno["detail"], detail)
+	}
+	if csq := transAnno.Consequences.String(transAnno.Transcript); csq != "" && pkg.FindArr(geneAnno["consequence"], csq) < 0 {
+		geneAnno["consequence"] = append(geneAnno["consequence"], csq)
+	}
+	for key, site := range map[string]string{"maxent_donor": "donor", "maxent_acceptor": "acceptor"} {
+		if score := transAnno.MaxEntScan(site); score != "" {
+			geneAnno[key] = append(geneAnno[key], score)
+		}
+	}
+	for key, hgvs := range map[string]string{"HGVSc": transAnno.HGVSc(), "HGVSp": transAnno.HGVSp()} {
+		if hgvs != "" && pkg.FindArr(geneAnno[key], hgvs) < 0 {
+			geneAnno[key] = append(geneAnno[key], hgvs)
+		}
+	}
+	if transAnno.Canonical != "" {
+		canonical := fmt.Sprintf("%s:%s", transAnno.Transcript, transAnno.Canonical)
+		if pkg.FindArr(geneAnno["canonical"], canonical) < 0 {
+			geneAnno["canonical"] = append(geneAnno["canonical"], canonical)
+		}
+	}
+	if transAnno.Biotype != "" {
+		biotype := fmt.Sprintf("%s:%s", transAnno.Transcript, transAnno.Biotype)
+		if pkg.FindArr(geneAnno["biotype"], biotype) < 0 {
+			geneAnno["biotype"] = append(geneAnno["biotype"], biotype)
+		}
+	}
+	for key, rank := range map[string]string{"exon": transAnno.Exon, "intron": transAnno.Intron} {
+		if rank != "" {
+			geneAnno[key] = append(geneAnno[key], fmt.Sprintf("%s:%s", transAnno.Transcript, rank))
+		}
+	}
+	if shift != "" && pkg.FindArr(geneAnno["hgvs_offset"], shift) < 0 {
+		geneAnno["hgvs_offset"] = append(geneAnno["hgvs_offset"], shift)
+	}
+	geneAnnos[transAnno.Gene] = geneAnno
+}
+
+// AnnoSnvTranses 注释SNV在所有重叠及上下游转录本上的功能
+func (this Options) AnnoSnvTranses(snv *pkg.SNV, tbx *bix.Bix, genome *faidx.Faidx) ([]TransAnno, error) {
 	annoVar := snv.AnnoVariant()
-	query, err := tbx.Query(snv)
+	transAnnos := make([]TransAnno, 0)
+	query, err := tbx.Query(pkg.NewPosition(annoVar.Chrom, annoVar.Start-this.UpDownStream, annoVar.End+this.UpDownStream))
 	if err != nil {
-		return map[string]any{}, err
+		return transAnnos, err
 	}
-	geneAnnos := make(map[string]map[string][]string)
+	defer query.Close()
 	for v, e := query.Next(); e == nil; v, e = query.Next() {
 		trans, err := pkg.NewTranscript(fmt.Sprintf("%s", v))
 		if err != nil {
-			return map[string]any{}, err
+			return transAnnos, err
 		}
+		trans.SetGeneID(this.GeneSymbols)
 		if trans.TxStart <= annoVar.End && trans.TxEnd >= annoVar.Start {
-			trans.SetGeneID()
 			err = trans.SetRegionsWithSeq(genome)
 			if err != nil {
-				return map[string]any{}, err
-			}
-			var transAnno TransAnno
-			if trans.IsUnk() {
-				if snv.Type() == pkg.VType_SNP {
-					transAnno = AnnoUnkSnp(annoVar, trans)
-				} else if snv.Type() == pkg.VType_INS {
-					transAnno = AnnoUnkIns(annoVar, trans)
-				} else if snv.Type() == pkg.VType_DEL {
-					transAnno = AnnoUnkDel(annoVar, trans)
-				} else {
-					transAnno = AnnoUnkSub(annoVar, trans)
-				}
-			} else {
-				if snv.Type() == pkg.VType_SNP {
-					transAnno = AnnoSnp(annoVar, trans)
-				} else if snv.Type() == pkg.VType_INS {
-					transAnno = AnnoIns(annoVar, trans)
-				} else if snv.Type() == pkg.VType_DEL {
-					transAnno = AnnoDel(annoVar, trans)
-				} else {
-					transAnno = AnnoSub(annoVar, trans)
-				}
-			}
-			geneAnno, ok := geneAnnos[transAnno.Gene]
-			if !ok {
-				geneAnno = map[string][]string{"gene": {transAnno.Gene}, "gene_id": {transAnno.GeneID}, "region": {}, "event": {}, "detail": {}}
-			}
-			region, event, detail := transAnno.Region, transAnno.Event, transAnno.Detail()
-			if region != "" && region != "." && pkg.FindArr(geneAnno["region"], region) < 0 {
-				geneAnno["region"] = append(geneAnno["region"], region)
+				return transAnnos, err
 			}
-			if event != "" && event != "." && pkg.FindArr(geneAnno["event"], event) < 0 {
-				geneAnno["event"] = append(geneAnno["event"], event)
-			}
-			if detail != "" && detail != "." && pkg.FindArr(geneAnno["detail"], detail) < 0 {
-				geneAnno["detail"] = append(geneAnno["detail"], detail)
-			}
-			geneAnnos[transAnno.Gene] = geneAnno
+			transAnnos = append(transAnnos, this.AnnoSnvTrans(annoVar, snv.Type(), trans))
+		} else {
+			transAnnos = append(transAnnos, this.AnnoUpDownStream(annoVar, trans))
 		}
 	}
-	query.Close()
+	return this.SelectTransAnnos(transAnnos), nil
+}
+
+// Codons SNV在各重叠编码转录本上所在的密码子，FORMAT=Chrom:Transcript:密码子序号，用于MNV分组
+func (this Options) Codons(snv *pkg.SNV, tbx *bix.Bix) ([]string, error) {
+	annoVar := snv.AnnoVariant()
+	codons := make([]string, 0)
+	query, err := tbx.Query(snv)
+	if err != nil {
+		return codons, err
+	}
+	defer query.Close()
+	for v, e := query.Next(); e == nil; v, e = query.Next() {
+		trans, err := pkg.NewTranscript(fmt.Sprintf("%s", v))
+		if err != nil {
+			return codons, err
+		}
+		if trans.IsUnk() || trans.TxStart > annoVar.Start || trans.TxEnd < annoVar.Start {
+			continue
+		}
+		trans.SetRegions()
+		if codon, ok := trans.Codon(annoVar.Start); ok {
+			codons = append(codons, fmt.Sprintf("%s:%s:%d", trans.Chrom, trans.Name, codon))
+		}
+	}
+	return codons, nil
+}
+
+// Genes 转录本注释结果中的基因名称，按首次出现的顺序
+func Genes(transAnnos []TransAnno) []string {
+	genes := make([]string, 0)
+	for _, transAnno := range transAnnos {
+		if pkg.FindArr(genes, transAnno.Gene) < 0 {
+			genes = append(genes, transAnno.Gene)
+		}
+	}
+	return genes
+}
+
+// GeneInfo 将转录本注释结果按基因合并为VCF INFO字段，同一基因内以|连接，不同基因间以,连接，基因顺序同Genes
+func GeneInfo(transAnnos []TransAnno) map[string]any {
+	geneAnnos := make(map[string]map[string][]string)
+	for _, transAnno := range transAnnos {
+		addGeneAnno(geneAnnos, transAnno)
+	}
 	annoData := make(map[string][]string)
-	for _, geneAnno := range geneAnnos {
+	for _, name := range Genes(transAnnos) {
+		geneAnno := geneAnnos[name]
 		for key, val := range geneAnno {
 			var value string
 			if key == "region" {
-				var regions1, regions2 []string
+				var regions1, regions2, regions3 []string
 				for _, region := range val {
 					switch region {
 					case "exonic", "splicing", "exonic_splicing", "transcript":
 						regions1 = append(regions1, region)
 					case "ncRNA", "UTR3", "UTR5", "intronic":
 						regions2 = append(regions2, region)
+					case "upstream", "downstream":
+						regions3 = append(regions3, region)
 					}
 				}
 				if len(regions1) > 0 {
 					value = strings.Join(regions1, "|")
-				} else {
-					if len(regions2) > 0 {
-						value = strings.Join(regions2, "|")
-					}
+				} else if len(regions2) > 0 {
+					value = strings.Join(regions2, "|")
+				} else if len(regions3) > 0 {
+					value = strings.Join(regions3, "|")
 				}
 			} else {
 				value = strings.Join(val, "|")
@@ -171,7 +322,16 @@ func AnnoSnv(snv *pkg.SNV, tbx *bix.Bix, genome *faidx.Faidx) (map[string]any, e
 	}
 	result := make(map[string]any)
 	for key, val := range annoData {
-		result[strings.ToUpper(key)] = strings.Join(val, ",")
+		if strings.HasPrefix(key, "HGVS") {
+			result[key] = strings.Join(val, ",")
+		} else {
+			result[strings.ToUpper(key)] = strings.Join(val, ",")
+		}
+	}
+	for _, key := range []string{"MAXENT_DONOR", "MAXENT_ACCEPTOR", "HGVS_OFFSET", "CANONICAL", "EXON", "INTRON"} {
+		if val, ok := result[key].(string); ok && strings.Trim(val, ".,") == "" {
+			delete(result, key)
+		}
 	}
-	return result, nil
+	return result
 }
@@ -6,7 +6,7 @@ import (
 	"strings"
 )
 
-func setSubAAChange(transAnno TransAnno, trans pkg.Transcript, cstart int, cend int, alt string) TransAnno {
+func (this Options) setSubAAChange(transAnno TransAnno, trans pkg.Transcript, cstart int, cend int, alt string) TransAnno {
 	cdna := trans.CDNA()
 	ncdna := pkg.Substitute2(cdna, cstart, cend, alt)
 	if trans.Strand == "-" {
@@ -23,6 +23,11 @@ func setSubAAChange(transAnno TransAnno, trans pkg.Transcript, cstart int, cend
 		}
 
 	}
+	// 替换碱基所在的密码子范围
+	codon1, codon2 := (cstart-1)/3, (cend-1)/3+1
+	if trans.Strand == "-" {
+		codon1, codon2 = (len(cdna)-cend)/3, (len(cdna)-cstart)/3+1
+	}
 	protein := pkg.Translate(cdna, trans.Chrom == "MT" || trans.Chrom == "chrM")
 	nprotein := pkg.Translate(ncdna, trans.Chrom == "MT" || trans.Chrom == "chrM")
 	start, end1, end2 := pkg.Difference(protein, nprotein)
@@ -30,36 +35,53 @@ func setSubAAChange(transAnno TransAnno, trans pkg.Transcript, cstart int, cend
 	aa2 := nprotein[start-1 : end2]
 	if (len(cdna)-len(ncdna))%3 == 0 {
 		transAnno.Event = "sub_inframe"
-		if len(aa2) == 0 {
+		if protein == nprotein {
+			transAnno.Event = "synonymous"
+			if codon1 < len(protein) && strings.Contains(protein[codon1:pkg.Min(codon2, len(protein))], "*") {
+				transAnno.Event = "stopretained"
+			}
+		} else if len(aa1) == 1 && len(aa2) == 1 {
+			// 同一密码子内的多个碱基替换，按单个氨基酸替换命名
+			if aa1[0] == 'M' && start == 1 {
+				transAnno.Event = "startloss"
+			} else if aa1[0] == '*' {
+				transAnno.Event = "stoploss"
+			} else if aa2[0] == '*' {
+				transAnno.Event = "nonsense"
+			} else {
+				transAnno.Event = "missense"
+			}
+			transAnno.AAChange = fmt.Sprintf("p.%s%d%s", pkg.AAName(aa1, this.AAShort), start, pkg.AAName(aa2, this.AAShort))
+		} else if len(aa2) == 0 {
 			if len(aa1) == 1 {
-				transAnno.AAChange = fmt.Sprintf("p.%s%ddel", pkg.AAName(aa1, AA_SHORT), start)
+				transAnno.AAChange = fmt.Sprintf("p.%s%ddel", pkg.AAName(aa1, this.AAShort), start)
 			} else if len(aa1) > 1 {
 				transAnno.AAChange = fmt.Sprintf(
 					"p.%s%d_%s%ddel",
-					pkg.AAName(aa1[0], AA_SHORT),
+					pkg.AAName(aa1[0], this.AAShort),
 					start,
-					pkg.AAName(aa1[len(aa1)-1], AA_SHORT),
+					pkg.AAName(aa1[len(aa1)-1], this.AAShort),
 					end1,
 				)
 			}
 		} else {
 			if len(aa1) == 1 {
-				transAnno.AAChange = fmt.Sprintf("p.%s%ddelins%s", pkg.AAName(aa1, AA_SHORT), start, pkg.AAName(aa2, AA_SHORT))
+				transAnno.AAChange = fmt.Sprintf("p.%s%ddelins%s", pkg.AAName(aa1, this.AAShort), start, pkg.AAName(aa2, this.AAShort))
 			} else if len(aa1) > 1 {
 				transAnno.AAChange = fmt.Sprintf(
 					"p.%s%d_%s%ddelins%s",
-					pkg.AAName(aa1[0], AA_SHORT),
+					pkg.AAName(aa1[0], this.AAShort),
 					start,
-					pkg.AAName(aa1[len(aa1)-1], AA_SHORT),
+					pkg.AAName(aa1[len(aa1)-1], this.AAShort),
 					end1,
-					pkg.AAName(aa2, AA_SHORT))
+					pkg.AAName(aa2, this.AAShort))
 			}
 		}
 	} else {
 		if start < len(protein) {
 			transAnno.Event = "sub_frameshift"
 			if aa2[0] == '*' {
-				transAnno.AAChange = fmt.Sprintf("p.%s%dfs", pkg.AAName(aa1[0], AA_SHORT), start)
+				transAnno.AAChange = fmt.Sprintf("p.%s%dfs", pkg.AAName(aa1[0], this.AAShort), start)
 			} else {
 				var fs string
 				fsi := strings.IndexByte(nprotein[start-1:], '*')
@@ -70,11 +92,11 @@ func setSubAAChange(transAnno TransAnno, trans pkg.Transcript, cstart int, cend
 					fs = fmt.Sprintf("%d", fsi+1)
 				}
 
-				transAnno.AAChange = fmt.Sprintf("p.%s%d%sfs*%s", pkg.AAName(aa1[0], AA_SHORT), start, pkg.AAName(aa2[0], AA_SHORT), fs)
+				transAnno.AAChange = fmt.Sprintf("p.%s%d%sfs*%s", pkg.AAName(aa1[0], this.AAShort), start, pkg.AAName(aa2[0], this.AAShort), fs)
 			}
 		}
 	}
-	if protein[0] != nprotein[0] && protein[0] == 'M' {
+	if protein[0] != nprotein[0] && protein[0] == 'M' && transAnno.Event != "startloss" {
 		transAnno.Event += "_startloss"
 	}
 	if strings.Contains(transAnno.Region, "splic") {
@@ -83,7 +105,7 @@ func setSubAAChange(transAnno TransAnno, trans pkg.Transcript, cstart int, cend
 	return transAnno
 }
 
-func AnnoSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+func (this Options) AnnoSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	utrLen1, utrLen2 := trans.ULen()
 	cdsLen := trans.CLen()
 	region1, cLen1, uLen1 := trans.Region(snv.Start)
@@ -92,7 +114,7 @@ func AnnoSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	utrPosOfNAchange2 := getUTRPosOfNAchange(trans, utrLen1, utrLen2, snv.End, uLen2, region2)
 	cdsPosOfNAchange1, dist1 := getCDSPosOfNAchange(trans, cdsLen, snv.Start, cLen1, region1)
 	cdsPosOfNAchange2, dist2 := getCDSPosOfNAchange(trans, cdsLen, snv.End, cLen2, region2)
-	transAnno := NewTransAnno(trans, region1, region2)
+	transAnno := this.NewTransAnno(trans, region1, region2)
 	if !region1.Equal(region2) {
 		if region2.Start-region1.End > 1 {
 			transAnno.Region = "deletion"
@@ -159,7 +181,7 @@ func AnnoSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 				} else {
 					transAnno.NAChange = fmt.Sprintf("c.%s_%sdelins%s", cdsPosOfNAchange2, cdsPosOfNAchange1, pkg.RevComp(snv.Alt))
 				}
-				if dist1 > 0 && dist2 > 0 && pkg.Min(dist1, dist2) <= 2 {
+				if dist1 > 0 && dist2 > 0 && pkg.Min(dist1, dist2) <= this.Splice.Site {
 					transAnno.Event = "splicing"
 					transAnno.Region = "splicing"
 				}
@@ -167,16 +189,16 @@ func AnnoSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 				cstart, cend := cLen1, cLen2
 				cstart += snv.Start - region1.Start + 1
 				cend += snv.End - region2.Start + 1
-				transAnno = setSubAAChange(transAnno, trans, cstart, cend, snv.Alt)
+				transAnno = this.setSubAAChange(transAnno, trans, cstart, cend, snv.Alt)
 			}
 		}
 	}
 	return transAnno
 }
 
-func AnnoUnkSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
+func (this Options) AnnoUnkSub(snv pkg.AnnoVariant, trans pkg.Transcript) TransAnno {
 	alt := snv.Alt
-	transAnno := NewTransAnno(trans)
+	transAnno := this.NewTransAnno(trans)
 	transAnno.Region2 = "ncRNA"
 	nstart := snv.Start - trans.TxStart + 1
 	nend := snv.End - trans.TxStart + 1
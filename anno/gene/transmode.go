@@ -0,0 +1,91 @@
+package gene
+
+import (
+	"open-anno/pkg"
+	"sort"
+)
+
+const (
+	TransMode_ALL  = "all"
+	TransMode_REP  = "rep"
+	TransMode_MANE = "mane"
+	TransMode_PICK = "pick"
+)
+
+// keepTrans 根据转录本选择模式判断是否保留该转录本
+func (this Options) keepTrans(canonical string) bool {
+	switch this.TransMode {
+	case TransMode_REP:
+		return canonical != ""
+	case TransMode_MANE:
+		return pkg.IsMANE(canonical)
+	}
+	return true
+}
+
+// severity SO后果的严重程度，数值越小越严重
+func (this Consequences) severity() int {
+	if len(this) == 0 {
+		return len(SOConsequences)
+	}
+	for i, csq := range SOConsequences {
+		if csq.Term == this[0].Term {
+			return i
+		}
+	}
+	return len(SOConsequences)
+}
+
+// SelectTransAnnos 根据转录本选择模式筛选SNV转录本注释结果，代表性转录本排在前面；
+// pick模式下每个基因只保留一个转录本，优先代表性转录本，其次后果最严重的转录本
+func (this Options) SelectTransAnnos(transAnnos []TransAnno) []TransAnno {
+	selected := make([]TransAnno, 0, len(transAnnos))
+	for _, transAnno := range transAnnos {
+		if this.keepTrans(transAnno.Canonical) {
+			selected = append(selected, transAnno)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if (selected[i].Canonical != "") != (selected[j].Canonical != "") {
+			return selected[i].Canonical != ""
+		}
+		return this.TransMode == TransMode_PICK && selected[i].Consequences.severity() < selected[j].Consequences.severity()
+	})
+	if this.TransMode != TransMode_PICK {
+		return selected
+	}
+	picked := make([]TransAnno, 0)
+	genes := make(map[string]bool)
+	for _, transAnno := range selected {
+		if !genes[transAnno.Gene] {
+			genes[transAnno.Gene] = true
+			picked = append(picked, transAnno)
+		}
+	}
+	return picked
+}
+
+// SelectCnvTransAnnos 根据转录本选择模式筛选CNV转录本注释结果，pick模式下每个基因只保留一个转录本
+func (this Options) SelectCnvTransAnnos(transAnnos []CnvTransAnno) []CnvTransAnno {
+	selected := make([]CnvTransAnno, 0, len(transAnnos))
+	for _, transAnno := range transAnnos {
+		if this.keepTrans(transAnno.Canonical) {
+			selected = append(selected, transAnno)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Canonical != "" && selected[j].Canonical == ""
+	})
+	if this.TransMode != TransMode_PICK {
+		return selected
+	}
+	picked := make([]CnvTransAnno, 0)
+	genes := make(map[string]bool)
+	for _, transAnno := range selected {
+		if !genes[transAnno.Gene] {
+			genes[transAnno.Gene] = true
+			picked = append(picked, transAnno)
+		}
+	}
+	return picked
+}
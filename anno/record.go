@@ -0,0 +1,161 @@
+package anno
+
+import (
+	"open-anno/anno/db"
+	"open-anno/anno/gene"
+	"open-anno/pkg"
+)
+
+// TransRecord 转录本注释结果，包含HGVS表达式
+type TransRecord struct {
+	gene.TransAnno
+	HGVSc string `json:"hgvsc,omitempty"`
+	HGVSp string `json:"hgvsp,omitempty"`
+}
+
+// GeneRecord 基因注释结果，包含该基因上的所有转录本
+type GeneRecord struct {
+	Gene        string            `json:"gene"`
+	GeneID      string            `json:"gene_id"`
+	Transcripts []TransRecord     `json:"transcripts"`
+	GeneBased   map[string]string `json:"genebased,omitempty"` // GeneBased数据库注释，以INFO字段名为键
+}
+
+// NewGeneRecords 将转录本注释结果按基因分组，基因顺序与转录本首次出现的顺序一致
+func NewGeneRecords(transAnnos []gene.TransAnno) []GeneRecord {
+	geneRecords := make([]GeneRecord, 0)
+	indexes := make(map[string]int)
+	for _, transAnno := range transAnnos {
+		idx, ok := indexes[transAnno.Gene]
+		if !ok {
+			idx = len(geneRecords)
+			indexes[transAnno.Gene] = idx
+			geneRecords = append(geneRecords, GeneRecord{Gene: transAnno.Gene, GeneID: transAnno.GeneID})
+		}
+		geneRecords[idx].Transcripts = append(geneRecords[idx].Transcripts, TransRecord{
+			TransAnno: transAnno,
+			HGVSc:     transAnno.HGVSc(),
+			HGVSp:     transAnno.HGVSp(),
+		})
+	}
+	return geneRecords
+}
+
+// geneBasedValues 基因的GeneBased数据库注释，基因不在数据库中时返回nil
+func geneBasedValues(annos []GeneBasedAnno, name string) map[string]string {
+	for _, anno := range annos {
+		if anno.Gene == name && len(anno.Values) > 0 {
+			return anno.Values
+		}
+	}
+	return nil
+}
+
+// MNVRecord 同一单倍型上相邻SNP合并后的注释结果
+type MNVRecord struct {
+	Name  string       `json:"name"`
+	HGVSg string       `json:"hgvsg"`
+	Genes []GeneRecord `json:"genes"`
+}
+
+// SnvRecord SNV的结构化注释结果，以NDJSON输出时每个变异一行
+type SnvRecord struct {
+	Chrom         string                       `json:"chrom"`
+	Pos           uint64                       `json:"pos"`
+	ID            string                       `json:"id,omitempty"`
+	Ref           string                       `json:"ref"`
+	Alt           string                       `json:"alt"`
+	Filter        string                       `json:"filter,omitempty"`
+	HGVSg         string                       `json:"hgvsg"`
+	Genes         []GeneRecord                 `json:"genes"`
+	NearestGenes  []gene.NearestGene           `json:"nearest_genes,omitempty"` // 基因间区变异左右两侧最近的基因
+	FilterBased   map[string]any               `json:"filterbased,omitempty"`
+	PositionBased map[string]float64           `json:"positionbased,omitempty"`
+	RegionBased   map[string][]db.RegionRecord `json:"regionbased,omitempty"`
+	MNVs          []MNVRecord                  `json:"mnvs,omitempty"`
+}
+
+// Record 转换为结构化注释结果
+func (this SnvResult) Record(snv *pkg.SNV) SnvRecord {
+	record := SnvRecord{
+		Chrom:         snv.Chrom(),
+		Pos:           snv.Pos,
+		Ref:           snv.Ref(),
+		Alt:           snv.Alt()[0],
+		HGVSg:         this.HGVSg,
+		Genes:         NewGeneRecords(this.Transcripts),
+		FilterBased:   this.FilterBased,
+		PositionBased: this.PositionBased,
+		RegionBased:   this.RegionBased,
+	}
+	if snv.Id() != "." {
+		record.ID = snv.Id()
+	}
+	if snv.Filter != "." {
+		record.Filter = snv.Filter
+	}
+	for i := range record.Genes {
+		record.Genes[i].GeneBased = geneBasedValues(this.GeneBased, record.Genes[i].Gene)
+	}
+	if len(this.Transcripts) == 0 {
+		for _, nearest := range this.NearestGenes {
+			if nearest.Gene != "" {
+				record.NearestGenes = append(record.NearestGenes, nearest)
+			}
+		}
+	}
+	for _, mnv := range this.MNVs {
+		record.MNVs = append(record.MNVs, MNVRecord{Name: mnv.Name, HGVSg: mnv.HGVSg, Genes: NewGeneRecords(mnv.Transcripts)})
+	}
+	return record
+}
+
+// CnvGeneRecord CNV基因注释结果，包含该基因上的所有转录本
+type CnvGeneRecord struct {
+	Gene        string              `json:"gene"`
+	GeneID      string              `json:"gene_id"`
+	Transcripts []gene.CnvTransAnno `json:"transcripts"`
+	GeneBased   map[string]string   `json:"genebased,omitempty"` // GeneBased数据库注释，以INFO字段名为键
+}
+
+// CnvRecord CNV的结构化注释结果，以NDJSON输出时每个变异一行
+type CnvRecord struct {
+	Chrom       string                       `json:"chrom"`
+	Start       uint64                       `json:"start"`
+	End         uint32                       `json:"end"`
+	ID          string                       `json:"id,omitempty"`
+	Type        string                       `json:"type"` // DEL or DUP
+	Alt         string                       `json:"alt"`
+	Genes       []CnvGeneRecord              `json:"genes"`
+	RegionBased map[string][]db.RegionRecord `json:"regionbased,omitempty"`
+}
+
+// Record 转换为结构化注释结果
+func (this CnvResult) Record(cnv *pkg.CNV) CnvRecord {
+	record := CnvRecord{
+		Chrom:       cnv.Chrom(),
+		Start:       cnv.Pos,
+		End:         cnv.End(),
+		Type:        cnv.Type(),
+		Alt:         cnv.Alt()[0],
+		Genes:       make([]CnvGeneRecord, 0),
+		RegionBased: this.RegionBased,
+	}
+	if cnv.Id() != "." {
+		record.ID = cnv.Id()
+	}
+	indexes := make(map[string]int)
+	for _, transAnno := range this.Transcripts {
+		idx, ok := indexes[transAnno.Gene]
+		if !ok {
+			idx = len(record.Genes)
+			indexes[transAnno.Gene] = idx
+			record.Genes = append(record.Genes, CnvGeneRecord{Gene: transAnno.Gene, GeneID: transAnno.GeneID})
+		}
+		record.Genes[idx].Transcripts = append(record.Genes[idx].Transcripts, transAnno)
+	}
+	for i := range record.Genes {
+		record.Genes[i].GeneBased = geneBasedValues(this.GeneBased, record.Genes[i].Gene)
+	}
+	return record
+}
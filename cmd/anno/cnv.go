@@ -1,15 +1,15 @@
 package anno
 
 import (
+	"encoding/json"
 	"log"
 	"open-anno/anno"
+	"open-anno/anno/gene"
 	"open-anno/pkg"
 	"os"
 	"path"
-	"strings"
 	"sync"
 
-	"github.com/brentp/bix"
 	"github.com/brentp/vcfgo"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
@@ -23,12 +23,23 @@ type AnnoCnvParam struct {
 	Output             string   `validate:"required"`
 	RegionBaseds       []string `validate:"pathsexists"`
 	RegionBasedIndexes []string `validate:"pathsexists"`
-	Overlap            float64  `validate:"required"`
-	Concurrency        int      `validate:"required"`
+	GeneBaseds         []string `validate:"pathsexists"`
+	Overlap            float64  `validate:"gte=0,lte=1"`
+	OverlapMode        string   `validate:"oneof=query target reciprocal jaccard any"`
+	OverlapDBs         []string
+	Concurrency        int    `validate:"required"`
+	TransMode          string `validate:"oneof=all rep mane pick"`
+	RepTrans           string `validate:"omitempty,pathexists"`
+	Protein            string `validate:"omitempty,pathexists"`
+	OutputFormat       string `validate:"oneof=vcf ndjson"`
+	Contigs            []string
+	PassThrough        bool
+	contigs            pkg.Contigs
 }
 
 func (this *AnnoCnvParam) Valid() error {
 	this.GenePredIndex = this.GenePred + ".tbi"
+	this.contigs = pkg.NewContigs(this.Contigs)
 	for _, db := range this.RegionBaseds {
 		this.RegionBasedIndexes = append(this.RegionBasedIndexes, db+".tbi")
 	}
@@ -39,6 +50,10 @@ func (this *AnnoCnvParam) Valid() error {
 	if err != nil {
 		return err
 	}
+	err = CheckRepTrans(this.TransMode, this.RepTrans, this.GenePred)
+	if err != nil {
+		return err
+	}
 	return os.MkdirAll(this.Outdir(), 0666)
 }
 
@@ -46,39 +61,82 @@ func (this AnnoCnvParam) Outdir() string {
 	return path.Dir(this.Output)
 }
 
-func (this *AnnoCnvParam) RunAnno(cnvs []*pkg.CNV, gpeTbx *bix.Bix, rbTbxs []*bix.Bix, dbnames []string) (map[string]map[string]any, error) {
+// Options 注释器参数，读取Gene及RepTrans文件
+func (this AnnoCnvParam) Options() (anno.Options, error) {
+	var err error
+	opts := anno.Options{
+		Options:      gene.DefaultOptions(),
+		GenePred:     this.GenePred,
+		RegionBaseds: this.RegionBaseds,
+		GeneBaseds:   this.GeneBaseds,
+	}
+	opts.TransMode = this.TransMode
+	opts.Overlap, opts.Overlaps, err = ParseOverlaps(this.OverlapMode, this.Overlap, this.OverlapDBs)
+	if err != nil {
+		return opts, err
+	}
+	// 读取GeneID信息
+	log.Printf("Read Gene: %s ...", this.Gene)
+	opts.GeneSymbols, err = pkg.ReadGeneSymbols(this.Gene)
+	if err != nil {
+		return opts, err
+	}
+	// 读取代表性转录本
+	if this.RepTrans != "" {
+		log.Printf("Read RepTrans: %s ...", this.RepTrans)
+		opts.RepTrans, err = pkg.ReadRepTranscripts(this.RepTrans)
+		if err != nil {
+			return opts, err
+		}
+	}
+	// 读取转录本登录号信息
+	if this.Protein != "" {
+		log.Printf("Read Protein: %s ...", this.Protein)
+		opts.Accessions, err = pkg.ReadTransAccessions(this.Protein)
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+func (this *AnnoCnvParam) RunAnno(cnvs []*pkg.CNV, annotator *anno.Annotator) (map[string]anno.CnvResult, error) {
 	cnvChan := make(chan *pkg.CNV, len(cnvs))
 	for _, snv := range cnvs {
 		cnvChan <- snv
 	}
 	close(cnvChan)
 	var wg sync.WaitGroup
-	resChan := make(chan anno.AnnoInfo, len(cnvs))
+	resChan := make(chan anno.CnvResult, len(cnvs))
 	for i := 0; i <= this.Concurrency; i++ {
 		wg.Add(1)
-		go anno.AnnoCnvWorker(cnvChan, gpeTbx, rbTbxs, dbnames, this.Overlap, resChan, &wg)
+		go annotator.CnvWorker(cnvChan, resChan, &wg)
 	}
 	go func() {
 		wg.Wait()
 		close(resChan)
 	}()
-	results := make(map[string]map[string]any)
+	results := make(map[string]anno.CnvResult)
 	for res := range resChan {
 		if res.Error != nil {
 			return results, res.Error
 		}
-		results[res.PK] = res.Data
+		results[res.PK] = res
 	}
 	return results, nil
 }
 
 func (this AnnoCnvParam) Run() error {
-	// 读取GeneID信息
-	log.Printf("Read Gene: %s ...", this.Gene)
-	err := pkg.InitGeneSymbolToID(this.Gene)
+	opts, err := this.Options()
+	if err != nil {
+		return err
+	}
+	// 打开GenePred、RegionBaseds及GeneBaseds
+	annotator, err := anno.NewAnnotator(opts)
 	if err != nil {
 		return err
 	}
+	defer annotator.Close()
 	// 打开变异输入文件
 	log.Printf("Read AnnoInput: %s ...", this.Input)
 	reader, err := pkg.NewIOReader(this.Input)
@@ -92,44 +150,65 @@ func (this AnnoCnvParam) Run() error {
 	}
 	defer vcfReader.Close()
 	vcfHeader := vcfReader.Header
-	// 打开GenePred
-	gpeTbx, err := bix.New(this.GenePred)
-	if err != nil {
-		return err
+	vcfHeader.Infos["GENE"] = &vcfgo.Info{
+		Id:          "GENE",
+		Description: "Gene Symbol",
+		Number:      ".",
+		Type:        "String",
+	}
+	vcfHeader.Infos["GENE_ID"] = &vcfgo.Info{
+		Id:          "GENE_ID",
+		Description: "Gene Entrez ID, in the same order as GENE",
+		Number:      ".",
+		Type:        "String",
 	}
-	defer gpeTbx.Close()
 	vcfHeader.Infos["DETAIL"] = &vcfgo.Info{
 		Id:          "DETAIL",
-		Description: "Gene detail, FORMAT=Gene:Transcript:Exon:NA_CHANGE:AA_CHANGE",
+		Description: "Gene detail, FORMAT=Gene:GeneID:Transcript:Strand:Region:CDS:Exon:Position",
 		Number:      ".",
 		Type:        "String",
 	}
-	// 打开RegionBaseds
-	rbTbxs := make([]*bix.Bix, len(this.RegionBaseds))
-	dbnames := make([]string, len(this.RegionBaseds))
-	for i, rbFile := range this.RegionBaseds {
-		rbTbxs[i], err = bix.New(rbFile)
-		if err != nil {
-			return err
+	vcfHeader.Infos["CANONICAL"] = &vcfgo.Info{
+		Id:          "CANONICAL",
+		Description: "Representative transcript of gene, FORMAT=Transcript:Source, Source: MANE_Select, MANE_Plus_Clinical, HGMD, Max_Length",
+		Number:      ".",
+		Type:        "String",
+	}
+	vcfHeader.Infos["EXON"] = &vcfgo.Info{
+		Id:          "EXON",
+		Description: "Exon numbers overlapped and total exons of transcript, FORMAT=Transcript:Exon/Total, eg: NM_000546.6:3-5/11",
+		Number:      ".",
+		Type:        "String",
+	}
+	vcfHeader.Infos["INTRON"] = &vcfgo.Info{
+		Id:          "INTRON",
+		Description: "Intron numbers overlapped and total introns of transcript, FORMAT=Transcript:Intron/Total, eg: NM_000546.6:2-4/10",
+		Number:      ".",
+		Type:        "String",
+	}
+	for i, dbname := range annotator.DBNames {
+		for _, info := range annotator.Schemas[i].HeaderInfos() {
+			vcfHeader.Infos[info.Id] = info
 		}
-		dbnames[i] = strings.Split(path.Base(rbFile), ".")[0]
-		vcfHeader.Infos[dbnames[i]] = &vcfgo.Info{
-			Id:          dbnames[i],
-			Description: dbnames[i],
-			Number:      ".",
-			Type:        "String",
+		vcfHeader.Infos[anno.OverlapKey(dbname)] = OverlapHeaderInfo(dbname)
+	}
+	for _, geneBased := range annotator.GeneDBs {
+		for _, info := range geneBased.HeaderInfos() {
+			vcfHeader.Infos[info.Id] = info
 		}
-		defer rbTbxs[i].Close()
 	}
 	// 读取变异
-	cnvs := make([]*pkg.CNV, 0)
+	cnvs, records := make([]*pkg.CNV, 0), make([]*pkg.CNV, 0)
 	for variant := vcfReader.Read(); variant != nil; variant = vcfReader.Read() {
-		if len(variant.Chrom()) > 5 {
+		cnv := &pkg.CNV{Variant: *variant}
+		if this.contigs.Allow(variant.Chrom()) {
+			cnvs = append(cnvs, cnv)
+		} else if !this.PassThrough {
 			continue
 		}
-		cnvs = append(cnvs, &pkg.CNV{Variant: *variant})
+		records = append(records, cnv)
 	}
-	annoResult, err := this.RunAnno(cnvs, gpeTbx, rbTbxs, dbnames)
+	annoResult, err := this.RunAnno(cnvs, annotator)
 	if err != nil {
 		return err
 	}
@@ -138,9 +217,28 @@ func (this AnnoCnvParam) Run() error {
 		return err
 	}
 	defer writer.Close()
+	if this.OutputFormat == "ndjson" {
+		encoder := json.NewEncoder(writer)
+		encoder.SetEscapeHTML(false)
+		for _, cnv := range cnvs {
+			err = encoder.Encode(annoResult[cnv.PK()].Record(cnv))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	vcfWriter, err := vcfgo.NewWriter(writer, vcfHeader)
-	for _, cnv := range cnvs {
-		for key, val := range annoResult[cnv.PK()] {
+	if err != nil {
+		return err
+	}
+	for _, cnv := range records {
+		result, ok := annoResult[cnv.PK()]
+		if !ok {
+			vcfWriter.WriteVariant(&cnv.Variant)
+			continue
+		}
+		for key, val := range result.Info() {
 			if val != "" && val != "." {
 				err = cnv.Info().Set(key, val)
 				if err != nil {
@@ -164,8 +262,17 @@ func NewAnnoCnvCmd() *cobra.Command {
 			param.Gene, _ = cmd.Flags().GetString("gene")
 			param.Output, _ = cmd.Flags().GetString("output")
 			param.RegionBaseds, _ = cmd.Flags().GetStringArray("regionbaseds")
+			param.GeneBaseds, _ = cmd.Flags().GetStringArray("genebaseds")
 			param.Overlap, _ = cmd.Flags().GetFloat64("overlap")
+			param.OverlapMode, _ = cmd.Flags().GetString("overlap_mode")
+			param.OverlapDBs, _ = cmd.Flags().GetStringArray("overlap_db")
 			param.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+			param.TransMode, _ = cmd.Flags().GetString("transcript_mode")
+			param.RepTrans, _ = cmd.Flags().GetString("reptrans")
+			param.Protein, _ = cmd.Flags().GetString("protein")
+			param.OutputFormat, _ = cmd.Flags().GetString("output_format")
+			param.Contigs, _ = cmd.Flags().GetStringSlice("contigs")
+			param.PassThrough, _ = cmd.Flags().GetBool("passthrough")
 			err := param.Valid()
 			if err != nil {
 				cmd.Help()
@@ -182,8 +289,17 @@ func NewAnnoCnvCmd() *cobra.Command {
 	cmd.Flags().StringP("gene", "g", "", "Input Gene Symbol To ID File")
 	cmd.Flags().StringP("gbname", "n", "", "Parameter Database Name")
 	cmd.Flags().StringP("output", "o", "", "AnnoOutput File")
-	cmd.Flags().StringArrayP("regionbaseds", "r", []string{}, "Input RegionBased Database File")
-	cmd.Flags().Float64P("overlap", "l", 0.7, "Parameter Database Name")
+	cmd.Flags().StringArrayP("regionbaseds", "r", []string{}, "Input RegionBased Database File, output columns are set by FILE.schema if exists, default: column 4")
+	cmd.Flags().StringArrayP("genebaseds", "B", []string{}, "Input GeneBased Database File, TSV with header, first column is GeneID(Entrez ID) or Gene Symbol")
+	cmd.Flags().Float64P("overlap", "l", 0.7, "Parameter Minimum Overlap Fraction of RegionBased Database")
+	cmd.Flags().String("overlap_mode", "query", "Parameter Overlap Mode of RegionBased Database, query, target, reciprocal, jaccard or any")
+	cmd.Flags().StringArray("overlap_db", []string{}, "Parameter Overlap of a RegionBased Database, FORMAT=DBName=Mode[:Fraction], eg: dgv=reciprocal:0.5")
 	cmd.Flags().IntP("concurrency", "c", 10000, "Parameter Concurrency Numbers")
+	cmd.Flags().String("transcript_mode", "all", "Parameter Transcript Mode, all, rep, mane or pick(one transcript per gene)")
+	cmd.Flags().String("reptrans", "", "Input Representative Transcript File from 'tools rt', MANE tags of GTF/GFF3 gene models from 'pre gtf' are used when absent")
+	cmd.Flags().StringP("protein", "P", "", "Input Transcript To Protein File from 'pre gene', provides versioned transcript accessions")
+	cmd.Flags().String("output_format", "vcf", "Parameter Output Format, vcf or ndjson(one JSON object per variant)")
+	cmd.Flags().StringSlice("contigs", []string{}, "Parameter Contigs to Annotate, comma separated, default 1-22, X, Y, M, MT with or without chr prefix, 'all' for every contig")
+	cmd.Flags().Bool("passthrough", false, "Parameter Write Records of Contigs not Annotated Unchanged in Input Order, vcf only")
 	return cmd
 }
@@ -0,0 +1,192 @@
+package anno
+
+import (
+	"fmt"
+	"log"
+	"open-anno/anno"
+	"open-anno/pkg"
+	"os"
+	"sync"
+
+	"github.com/brentp/bix"
+	"github.com/brentp/faidx"
+	"github.com/brentp/vcfgo"
+)
+
+// SNV_CHUNK_SIZE 每个注释批次的变异数
+const SNV_CHUNK_SIZE = 200
+
+// fbBucket FilterBasedDirs中同一区间的数据库句柄，该区间所有批次注释完成后关闭
+type fbBucket struct {
+	key  string
+	tbxs []*bix.Bix
+	wg   sync.WaitGroup
+}
+
+// release 等待区间内所有批次注释完成后关闭数据库句柄
+func (this *fbBucket) release() {
+	go func() {
+		this.wg.Wait()
+		for _, tbx := range this.tbxs {
+			tbx.Close()
+		}
+	}()
+}
+
+// openBucket 打开FilterBasedDirs中区间key对应的数据库文件，文件不存在时跳过
+func (this AnnoSnvParam) openBucket(key string) (*fbBucket, error) {
+	bucket := &fbBucket{key: key}
+	for _, fbDir := range this.FilterBasedDirs {
+		fbFile := fmt.Sprintf("%s/%s.vcf.gz", fbDir, key)
+		_, err := os.Stat(fbFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		fbTbx, err := bix.New(fbFile)
+		if err != nil {
+			bucket.release()
+			return bucket, err
+		}
+		bucket.tbxs = append(bucket.tbxs, fbTbx)
+	}
+	return bucket, nil
+}
+
+// snvChunk 输入中连续的一批记录，注释MNV时跨FilterBasedDirs区间边界的相邻SNP也位于同一批次
+type snvChunk struct {
+	index      int
+	records    []*pkg.SNV  // 按输入顺序输出的所有记录，包括原样输出的记录
+	snvs       []*pkg.SNV  // 需要注释的变异
+	snvBuckets []*fbBucket // 每个变异所属的区间，与snvs一一对应
+	buckets    []*fbBucket // 批次中涉及的区间
+	boundary   uint64      // 达到分批条件时最后一个变异的位置，相邻SNP最多延伸到其后MNV_DISTANCE bp
+	results    map[string]anno.SnvResult
+	err        error
+}
+
+// add 加入需要注释的变异，区间首次加入批次时计数，批次注释完成后释放
+func (this *snvChunk) add(snv *pkg.SNV, bucket *fbBucket) {
+	this.records = append(this.records, snv)
+	this.snvs = append(this.snvs, snv)
+	this.snvBuckets = append(this.snvBuckets, bucket)
+	if len(this.buckets) == 0 || this.buckets[len(this.buckets)-1] != bucket {
+		bucket.wg.Add(1)
+		this.buckets = append(this.buckets, bucket)
+	}
+}
+
+// done 批次中的区间计数完成
+func (this *snvChunk) done() {
+	for _, bucket := range this.buckets {
+		bucket.wg.Done()
+	}
+}
+
+// neighbour 注释MNV时snv与批次中最后一个变异可能位于同一密码子，需位于同一批次，
+// 批次最多延伸到分批边界之后MNV_DISTANCE bp
+func (this AnnoSnvParam) neighbour(chunk *snvChunk, snv *pkg.SNV) bool {
+	if !this.MNV || len(chunk.snvs) == 0 {
+		return false
+	}
+	last := chunk.snvs[len(chunk.snvs)-1]
+	if last.Chrom() != snv.Chrom() || pkg.Abs(int(snv.Pos)-int(last.Pos)) > pkg.MNV_DISTANCE {
+		return false
+	}
+	if chunk.boundary == 0 {
+		chunk.boundary = last.Pos
+	}
+	return snv.Pos <= chunk.boundary+pkg.MNV_DISTANCE
+}
+
+// ReadChunks 流式读取变异，按输入顺序分批发送，window限制正在注释及等待输出的批次数
+func (this AnnoSnvParam) ReadChunks(vcfReader *vcfgo.Reader, genome *faidx.Faidx, chunks chan *snvChunk, window chan struct{}, done chan struct{}) error {
+	var bucket *fbBucket
+	chunk := &snvChunk{}
+	defer func() {
+		// 未发送的批次计数完成，出错返回时区间句柄也能关闭
+		chunk.done()
+		if bucket != nil {
+			bucket.release()
+		}
+		close(chunks)
+	}()
+	send := func() bool {
+		if len(chunk.records) == 0 {
+			return true
+		}
+		select {
+		case window <- struct{}{}:
+		case <-done:
+			return false
+		}
+		chunks <- chunk
+		chunk = &snvChunk{index: chunk.index + 1}
+		return true
+	}
+	for variant := vcfReader.Read(); variant != nil; variant = vcfReader.Read() {
+		chrom := variant.Chrom()
+		if !this.Annotatable(chrom) {
+			if this.PassThrough {
+				if len(chunk.records) >= SNV_CHUNK_SIZE && !send() {
+					return nil
+				}
+				chunk.records = append(chunk.records, &pkg.SNV{Variant: *variant})
+			}
+			continue
+		}
+		// 多等位位点按ALT拆分，分别注释
+		for _, snv := range (&pkg.SNV{Variant: *variant}).SplitAlts() {
+			if this.Normalize {
+				err := this.NormalizeSnv(snv, genome)
+				if err != nil {
+					return err
+				}
+			}
+			key := fmt.Sprintf("%s.%d", chrom, snv.Pos/uint64(pkg.FilterBasedBucketSize))
+			newBucket := bucket == nil || bucket.key != key
+			if newBucket || len(chunk.records) >= SNV_CHUNK_SIZE {
+				if !this.neighbour(chunk, snv) && !send() {
+					return nil
+				}
+			} else {
+				chunk.boundary = 0
+			}
+			if newBucket {
+				if bucket != nil {
+					bucket.release()
+				}
+				log.Printf("Run Annotating %s ...", key)
+				var err error
+				bucket, err = this.openBucket(key)
+				if err != nil {
+					bucket = nil
+					return err
+				}
+			}
+			chunk.add(snv, bucket)
+		}
+	}
+	send()
+	return nil
+}
+
+// AnnoChunks 注释批次中的变异，结果写入results
+func (this AnnoSnvParam) AnnoChunks(annotator *anno.Annotator, chunks chan *snvChunk, results chan *snvChunk, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for chunk := range chunks {
+		chunk.results = make(map[string]anno.SnvResult)
+		for i, snv := range chunk.snvs {
+			result, err := annotator.AnnotateSNV(snv, chunk.snvBuckets[i].tbxs...)
+			if err != nil {
+				chunk.err = err
+				break
+			}
+			chunk.results[snv.PK()] = result
+		}
+		if chunk.err == nil && this.MNV {
+			chunk.err = this.AnnoMNVs(chunk.snvs, annotator, chunk.results)
+		}
+		chunk.done()
+		results <- chunk
+	}
+}
@@ -0,0 +1,74 @@
+package anno
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brentp/vcfgo"
+)
+
+func newTestVcfReader(t *testing.T, positions []int) *vcfgo.Reader {
+	var builder strings.Builder
+	builder.WriteString("##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n")
+	for _, pos := range positions {
+		builder.WriteString(fmt.Sprintf("chr1\t%d\t.\tC\tA\t.\tPASS\t.\n", pos))
+	}
+	reader, err := vcfgo.NewReader(strings.NewReader(builder.String()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+func testPositions(start, step, n int) []int {
+	positions := make([]int, n)
+	for i := range positions {
+		positions[i] = start + i*step
+	}
+	return positions
+}
+
+func TestReadChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		mnv       bool
+		positions []int
+		sizes     []int
+		buckets   []int
+	}{
+		{"chunk size", false, testPositions(1000, 1, SNV_CHUNK_SIZE+5), []int{SNV_CHUNK_SIZE, 5}, []int{1, 1}},
+		{"no neighbour", true, testPositions(1000, 3, SNV_CHUNK_SIZE+5), []int{SNV_CHUNK_SIZE, 5}, []int{1, 1}},
+		{"neighbour extension capped", true, testPositions(1000, 1, SNV_CHUNK_SIZE+10), []int{SNV_CHUNK_SIZE + 2, 8}, []int{1, 1}},
+		{"neighbour across bucket", true, []int{9999999, 10000000, 10000001, 10000005}, []int{4}, []int{2}},
+		{"bucket without neighbour", false, []int{9999999, 10000000}, []int{1, 1}, []int{1, 1}},
+	}
+	for _, test := range tests {
+		param := AnnoSnvParam{MNV: test.mnv}
+		chunks, window, done := make(chan *snvChunk, len(test.positions)), make(chan struct{}, len(test.positions)), make(chan struct{})
+		if err := param.ReadChunks(newTestVcfReader(t, test.positions), nil, chunks, window, done); err != nil {
+			t.Fatal(err)
+		}
+		sizes, buckets := make([]int, 0), make([]int, 0)
+		for chunk := range chunks {
+			sizes = append(sizes, len(chunk.snvs))
+			buckets = append(buckets, len(chunk.buckets))
+			chunk.done()
+		}
+		if fmt.Sprint(sizes) != fmt.Sprint(test.sizes) || fmt.Sprint(buckets) != fmt.Sprint(test.buckets) {
+			t.Errorf("%s: got %v %v, want %v %v", test.name, sizes, buckets, test.sizes, test.buckets)
+		}
+	}
+}
+
+func TestReadChunksDone(t *testing.T) {
+	param := AnnoSnvParam{}
+	chunks, window, done := make(chan *snvChunk, 1), make(chan struct{}), make(chan struct{})
+	close(done)
+	if err := param.ReadChunks(newTestVcfReader(t, testPositions(1000, 1, SNV_CHUNK_SIZE+1)), nil, chunks, window, done); err != nil {
+		t.Fatal(err)
+	}
+	if chunk, ok := <-chunks; ok {
+		t.Errorf("got chunk %d, want closed channel", chunk.index)
+	}
+}
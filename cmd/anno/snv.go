@@ -1,14 +1,18 @@
 package anno
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"open-anno/anno"
+	"open-anno/anno/db"
 	"open-anno/anno/gene"
 	"open-anno/pkg"
 	"os"
 	"path"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
@@ -21,36 +25,57 @@ import (
 )
 
 type AnnoSnvParam struct {
-	Input              string `validate:"required,pathexists"`
-	GenePred           string `validate:"required,pathexists"`
-	GenePredIndex      string `validate:"required,pathexists"`
-	Genome             string `validate:"required,pathexists"`
-	GenomeIndex        string `validate:"required,pathexists"`
-	Gene               string `validate:"required,pathexists"`
-	Output             string `validate:"required"`
-	AAshort            bool
-	Exon               bool
-	FilterBaseds       []string `validate:"pathsexists"`
-	FilterBasedIndexes []string `validate:"pathsexists"`
-	RegionBaseds       []string `validate:"pathsexists"`
-	RegionBasedIndexes []string `validate:"pathsexists"`
-	FilterBasedDirs    []string `validate:"pathsexists"`
-	Overlap            float64  `validate:"required"`
-	Concurrency        int      `validate:"required"`
-	Chrom              string
+	Input               string `validate:"required,pathexists"`
+	GenePred            string `validate:"required,pathexists"`
+	GenePredIndex       string `validate:"required,pathexists"`
+	Genome              string `validate:"required,pathexists"`
+	GenomeIndex         string `validate:"required,pathexists"`
+	Gene                string `validate:"required,pathexists"`
+	Protein             string `validate:"omitempty,pathexists"`
+	Build               string `validate:"oneof=GRCh37 GRCh38"`
+	Output              string `validate:"required"`
+	AAshort             bool
+	Exon                bool
+	FilterBaseds        []string `validate:"pathsexists"`
+	FilterBasedIndexes  []string `validate:"pathsexists"`
+	RegionBaseds        []string `validate:"pathsexists"`
+	RegionBasedIndexes  []string `validate:"pathsexists"`
+	GeneBaseds          []string `validate:"pathsexists"`
+	Scores              []string
+	FilterBasedDirs     []string `validate:"pathsexists"`
+	FilterBasedLevelDBs []string `validate:"pathsexists"`
+	Overlap             float64  `validate:"gte=0,lte=1"`
+	OverlapMode         string   `validate:"oneof=query target reciprocal jaccard any"`
+	OverlapDBs          []string
+	Concurrency         int `validate:"required"`
+	Chrom               string
+	Contigs             []string
+	PassThrough         bool
+	SpliceSite          int `validate:"min=1"`
+	SpliceRegionExon    int `validate:"min=0"`
+	SpliceRegionIntron  int `validate:"gtefield=SpliceSite"`
+	Polypyrimidine      int `validate:"min=0"`
+	MaxEntScan          bool
+	MaxEntScanDir       string `validate:"omitempty,pathexists"`
+	UpDownStream        int    `validate:"min=0"`
+	OutputFormat        string `validate:"oneof=vcf tsv csq ndjson"`
+	TransMode           string `validate:"oneof=all rep mane pick"`
+	RepTrans            string `validate:"omitempty,pathexists"`
+	Normalize           bool
+	MNV                 bool
+	contigs             pkg.Contigs
 }
 
 func (this *AnnoSnvParam) Valid() error {
 	this.GenePredIndex = this.GenePred + ".tbi"
 	this.GenomeIndex = this.Genome + ".fai"
+	this.contigs = pkg.NewContigs(this.Contigs)
 	for _, db := range this.FilterBaseds {
 		this.FilterBasedIndexes = append(this.FilterBasedIndexes, db+".tbi")
 	}
 	for _, db := range this.RegionBaseds {
 		this.RegionBasedIndexes = append(this.RegionBasedIndexes, db+".tbi")
 	}
-	pkg.IS_EXON_REGION = this.Exon
-	gene.AA_SHORT = this.AAshort
 	validate := validator.New()
 	validate.RegisterValidation("pathexists", pkg.CheckPathExists)
 	validate.RegisterValidation("pathsexists", pkg.CheckPathsExists)
@@ -58,6 +83,13 @@ func (this *AnnoSnvParam) Valid() error {
 	if err != nil {
 		return err
 	}
+	err = CheckRepTrans(this.TransMode, this.RepTrans, this.GenePred)
+	if err != nil {
+		return err
+	}
+	if this.MaxEntScan && this.MaxEntScanDir == "" && !pkg.HasEmbeddedMaxEntScan() {
+		return fmt.Errorf("--maxentscan_dir is required for --maxentscan, MaxEntScan models are not embedded in this build")
+	}
 	return os.MkdirAll(this.Outdir(), 0666)
 }
 
@@ -65,38 +97,264 @@ func (this AnnoSnvParam) Outdir() string {
 	return path.Dir(this.Output)
 }
 
-func (this AnnoSnvParam) RunAnno(snvs []*pkg.SNV, gpeTbx *bix.Bix, fbTbxs []*bix.Bix, rbTbxs []*bix.Bix, dbnames []string, genome *faidx.Faidx) (map[string]map[string]any, error) {
-	snvChan := make(chan *pkg.SNV, len(snvs))
-	for _, snv := range snvs {
-		snvChan <- snv
+// CheckRepTrans 非all转录本模式需有代表性转录本文件，或GenePred中带有 pre gtf 输出的MANE标签
+func CheckRepTrans(transMode, repTrans, genePred string) error {
+	if transMode == "all" || repTrans != "" {
+		return nil
 	}
-	close(snvChan)
-	var wg sync.WaitGroup
-	resChan := make(chan anno.AnnoInfo, len(snvs))
-	for i := 0; i <= this.Concurrency; i++ {
-		wg.Add(1)
-		go anno.AnnoSnvWorker(snvChan, gpeTbx, fbTbxs, rbTbxs, dbnames, genome, this.Overlap, resChan, &wg)
+	hasMANE, err := pkg.GenePredHasMANE(genePred)
+	if err != nil {
+		return err
 	}
-	go func() {
-		wg.Wait()
-		close(resChan)
-	}()
-	results := make(map[string]map[string]any)
-	for res := range resChan {
-		if res.Error != nil {
-			return results, res.Error
+	if !hasMANE {
+		return fmt.Errorf("--reptrans is required for transcript_mode %s, unless the GenePred from 'pre gtf' carries MANE tags", transMode)
+	}
+	return nil
+}
+
+// ParseOverlaps 解析RegionBased数据库的默认重叠方式及各数据库的重叠方式，FORMAT=DBName=Mode[:Fraction]
+func ParseOverlaps(mode string, fraction float64, specs []string) (db.Overlap, map[string]db.Overlap, error) {
+	overlaps := make(map[string]db.Overlap)
+	overlap, err := db.ParseOverlap(mode, fraction)
+	if err != nil {
+		return overlap, overlaps, err
+	}
+	for _, spec := range specs {
+		dbname, text, found := strings.Cut(spec, "=")
+		if !found {
+			return overlap, overlaps, fmt.Errorf("overlap of database should be DBName=Mode[:Fraction]: %s", spec)
+		}
+		overlaps[dbname], err = db.ParseOverlap(text, fraction)
+		if err != nil {
+			return overlap, overlaps, err
 		}
-		results[res.PK] = res.Data
 	}
-	return results, nil
+	return overlap, overlaps, nil
 }
-func (this AnnoSnvParam) GetHeaderInfos() (map[string]*vcfgo.Info, []string, error) {
-	infos := map[string]*vcfgo.Info{
-		"GENE":    {Id: "GENE", Description: "Gene Symbol", Number: ".", Type: "String"},
-		"GENE_ID": {Id: "GENE_ID", Description: "Gene Entrez ID", Number: ".", Type: "String"},
-		"REGION":  {Id: "REGION", Description: "Region in gene, eg: exonic, intronic, UTR3, UTR5", Number: ".", Type: "String"},
-		"EVENT":   {Id: "EVENT", Description: "Variant Event, eg: missense, nonsense, splicing", Number: ".", Type: "String"},
-		"DETAIL":  {Id: "DETAIL", Description: "Gene detail, FORMAT=Gene:Transcript:Exon:NA_CHANGE:AA_CHANGE", Number: ".", Type: "String"},
+
+// Options 注释器参数，读取Gene、RepTrans、Protein及MaxEntScan文件
+func (this AnnoSnvParam) Options() (anno.Options, error) {
+	var err error
+	opts := anno.Options{
+		Options: gene.Options{
+			AAShort:      this.AAshort,
+			ExonRegion:   this.Exon,
+			UpDownStream: this.UpDownStream,
+			TransMode:    this.TransMode,
+			Build:        this.Build,
+			Splice: pkg.SpliceLens{
+				Site:           this.SpliceSite,
+				RegionExon:     this.SpliceRegionExon,
+				RegionIntron:   this.SpliceRegionIntron,
+				Polypyrimidine: this.Polypyrimidine,
+			},
+		},
+		GenePred:            this.GenePred,
+		Genome:              this.Genome,
+		FilterBaseds:        this.FilterBaseds,
+		FilterBasedLevelDBs: this.FilterBasedLevelDBs,
+		RegionBaseds:        this.RegionBaseds,
+		GeneBaseds:          this.GeneBaseds,
+	}
+	opts.Overlap, opts.Overlaps, err = ParseOverlaps(this.OverlapMode, this.Overlap, this.OverlapDBs)
+	if err != nil {
+		return opts, err
+	}
+	for _, spec := range this.Scores {
+		conf, err := db.ParsePositionBasedConf(spec)
+		if err != nil {
+			return opts, err
+		}
+		if _, err = os.Stat(conf.File); err != nil {
+			return opts, err
+		}
+		opts.PositionBaseds = append(opts.PositionBaseds, conf)
+	}
+	// 读取GeneID信息
+	log.Printf("Read Gene: %s ...", this.Gene)
+	opts.GeneSymbols, err = pkg.ReadGeneSymbols(this.Gene)
+	if err != nil {
+		return opts, err
+	}
+	// 读取代表性转录本
+	if this.RepTrans != "" {
+		log.Printf("Read RepTrans: %s ...", this.RepTrans)
+		opts.RepTrans, err = pkg.ReadRepTranscripts(this.RepTrans)
+		if err != nil {
+			return opts, err
+		}
+	}
+	// 读取MaxEntScan模型
+	if this.MaxEntScan {
+		log.Printf("Read MaxEntScan Models ...")
+		opts.MaxEntScan, err = pkg.ReadMaxEntScan(this.MaxEntScanDir)
+		if err != nil {
+			return opts, err
+		}
+	}
+	// 读取转录本及蛋白登录号信息
+	if this.Protein != "" {
+		log.Printf("Read Protein: %s ...", this.Protein)
+		opts.Accessions, err = pkg.ReadTransAccessions(this.Protein)
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// infoValue 将INFO值格式化为字符串，列表以,连接
+func infoValue(val any) string {
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Slice {
+		return fmt.Sprint(val)
+	}
+	texts := make([]string, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		texts[i] = fmt.Sprint(value.Index(i).Interface())
+	}
+	return strings.Join(texts, ",")
+}
+
+// TsvHeader 转录本水平TSV输出的表头
+var TsvHeader = []string{"Chrom", "Pos", "Ref", "Alt", "Gene", "GeneID", "Transcript", "Biotype", "Region", "Region2", "NAChange", "AAChange", "Event", "Exon", "Intron", "Canonical"}
+
+// WriteTsv 每个变异的每个转录本输出一行，之后为所有数据库注释字段
+func (this AnnoSnvParam) WriteTsv(writer io.Writer, snv *pkg.SNV, result anno.SnvResult, dbKeys []string) error {
+	var dbValues []string
+	info := result.Info()
+	for _, key := range dbKeys {
+		value := "."
+		if val, ok := info[key]; ok && infoValue(val) != "" {
+			value = infoValue(val)
+		}
+		dbValues = append(dbValues, value)
+	}
+	variant := []string{snv.Chrom(), fmt.Sprint(snv.Pos), snv.Ref(), strings.Join(snv.Alt(), ",")}
+	transAnnos := result.Transcripts
+	if len(transAnnos) == 0 {
+		transAnnos = []gene.TransAnno{gene.NewIntergenicAnno()}
+	}
+	for _, transAnno := range transAnnos {
+		row := []string{transAnno.Gene, transAnno.GeneID, transAnno.Transcript, transAnno.Biotype, transAnno.Region, transAnno.Region2, transAnno.NAChange, transAnno.AAChange, transAnno.Event, transAnno.Exon, transAnno.Intron, transAnno.Canonical}
+		for i, val := range row {
+			if val == "" {
+				row[i] = "."
+			}
+		}
+		row = append(append(variant, row...), dbValues...)
+		_, err := fmt.Fprintf(writer, "%s\n", strings.Join(row, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON 每个变异输出一行JSON
+func (this AnnoSnvParam) WriteJSON(writer io.Writer, snv *pkg.SNV, result anno.SnvResult) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(result.Record(snv))
+}
+
+// CsqFields VEP风格CSQ字段
+var CsqFields = []string{"Allele", "Consequence", "IMPACT", "SYMBOL", "Gene", "Feature_type", "Feature", "BIOTYPE", "EXON", "INTRON", "HGVSc", "HGVSp", "DISTANCE", "REGION", "EVENT", "CANONICAL", "MANE"}
+
+// csqEscape 去除CSQ值中的VCF保留字符
+func csqEscape(value string) string {
+	return strings.NewReplacer(",", "&", "|", "&", ";", "%3B", "=", "%3D", " ", "_").Replace(value)
+}
+
+// CSQ 每个转录本一个以|分隔的条目，之后为FilterBased数据库字段
+func (this AnnoSnvParam) CSQ(snv *pkg.SNV, result anno.SnvResult, fbKeys []string) string {
+	allele := snv.AnnoVariant().Alt
+	var dbValues []string
+	info := result.Info()
+	for _, key := range fbKeys {
+		var value string
+		if val, ok := info[key]; ok && infoValue(val) != "." {
+			value = csqEscape(infoValue(val))
+		}
+		dbValues = append(dbValues, value)
+	}
+	transAnnos := result.Transcripts
+	if len(transAnnos) == 0 {
+		transAnnos = []gene.TransAnno{gene.NewIntergenicAnno()}
+	}
+	entries := make([]string, len(transAnnos))
+	for i, transAnno := range transAnnos {
+		var featureType, distance, geneID, canonical, mane string
+		if transAnno.Transcript != "" {
+			featureType = "Transcript"
+		}
+		if transAnno.Distance > 0 {
+			distance = fmt.Sprint(transAnno.Distance)
+		}
+		if transAnno.GeneID != "." {
+			geneID = transAnno.GeneID
+		}
+		if transAnno.Canonical != "" {
+			canonical = "YES"
+			if pkg.IsMANE(transAnno.Canonical) {
+				mane = transAnno.Canonical
+			}
+		}
+		fields := []string{allele, transAnno.Consequences.Terms(), transAnno.Consequences.Impact(), transAnno.Gene, geneID, featureType, transAnno.Transcript, transAnno.Biotype, transAnno.Exon, transAnno.Intron, transAnno.HGVSc(), transAnno.HGVSp(), distance, transAnno.Region, transAnno.Event, canonical, mane}
+		for j, field := range fields {
+			fields[j] = csqEscape(field)
+		}
+		entries[i] = strings.Join(append(fields, dbValues...), "|")
+	}
+	return strings.Join(entries, ",")
+}
+
+// OverlapHeaderInfo RegionBased数据库重叠比例的VcfHeaderInfo
+func OverlapHeaderInfo(dbname string) *vcfgo.Info {
+	id := anno.OverlapKey(dbname)
+	return &vcfgo.Info{Id: id, Description: fmt.Sprintf("Overlap fraction of each %s record, in the same order as %s", dbname, dbname), Number: ".", Type: "String"}
+}
+
+// GeneHeaderInfos 基因注释的VcfHeaderInfo
+func (this AnnoSnvParam) GeneHeaderInfos() map[string]*vcfgo.Info {
+	return map[string]*vcfgo.Info{
+		"GENE":            {Id: "GENE", Description: "Gene Symbol", Number: ".", Type: "String"},
+		"GENE_ID":         {Id: "GENE_ID", Description: "Gene Entrez ID", Number: ".", Type: "String"},
+		"REGION":          {Id: "REGION", Description: "Region in gene, eg: exonic, intronic, UTR3, UTR5, ncRNA", Number: ".", Type: "String"},
+		"EVENT":           {Id: "EVENT", Description: "Variant Event, eg: missense, nonsense, splicing", Number: ".", Type: "String"},
+		"DETAIL":          {Id: "DETAIL", Description: "Gene detail, FORMAT=Gene:Transcript:Exon:NA_CHANGE:AA_CHANGE", Number: ".", Type: "String"},
+		"CONSEQUENCE":     {Id: "CONSEQUENCE", Description: "Sequence Ontology consequence, FORMAT=Transcript:SO_TERM&...:SO_ACCESSION&...:IMPACT", Number: ".", Type: "String"},
+		"MAXENT_DONOR":    {Id: "MAXENT_DONOR", Description: "MaxEntScan score of nearest splice donor, FORMAT=Transcript:RefScore:AltScore:Delta", Number: ".", Type: "String"},
+		"MAXENT_ACCEPTOR": {Id: "MAXENT_ACCEPTOR", Description: "MaxEntScan score of nearest splice acceptor, FORMAT=Transcript:RefScore:AltScore:Delta", Number: ".", Type: "String"},
+		"NEAREST_GENE":    {Id: "NEAREST_GENE", Description: "Nearest genes of intergenic variant on left and right, FORMAT=Gene:GeneID:Distance", Number: ".", Type: "String"},
+		"HGVSg":           {Id: "HGVSg", Description: "HGVS genomic expression, eg: NC_000017.11:g.7675088C>T", Number: ".", Type: "String"},
+		"HGVSc":           {Id: "HGVSc", Description: "HGVS transcript expression, eg: NM_000546.6:c.215C>G", Number: ".", Type: "String"},
+		"HGVSp":           {Id: "HGVSp", Description: "HGVS protein expression, eg: NP_000537.3:p.Pro72Arg", Number: ".", Type: "String"},
+		"HGVS_OFFSET":     {Id: "HGVS_OFFSET", Description: "HGVS 3' shift of indel in transcript, FORMAT=Transcript:Offset[:boundary]", Number: ".", Type: "String"},
+		"MNV":             {Id: "MNV", Description: "Phased neighbour SNPs annotated jointly, FORMAT=Chrom:Pos:Ref/Alt", Number: ".", Type: "String"},
+		"MNV_DETAIL":      {Id: "MNV_DETAIL", Description: "Gene detail of MNV, FORMAT=Gene:Transcript:Exon:NA_CHANGE:AA_CHANGE", Number: ".", Type: "String"},
+		"MNV_EVENT":       {Id: "MNV_EVENT", Description: "Variant Event of MNV", Number: ".", Type: "String"},
+		"MNV_HGVSg":       {Id: "MNV_HGVSg", Description: "HGVS genomic expression of MNV", Number: ".", Type: "String"},
+		"MNV_HGVSc":       {Id: "MNV_HGVSc", Description: "HGVS transcript expression of MNV", Number: ".", Type: "String"},
+		"MNV_HGVSp":       {Id: "MNV_HGVSp", Description: "HGVS protein expression of MNV", Number: ".", Type: "String"},
+		"BIOTYPE":         {Id: "BIOTYPE", Description: "Biotype of transcript which drove the annotation, FORMAT=Transcript:Biotype, eg: protein_coding, lncRNA, miRNA, mature_miRNA, processed_pseudogene", Number: ".", Type: "String"},
+		"EXON":            {Id: "EXON", Description: "Exon number and total exons of transcript, FORMAT=Transcript:Exon/Total, eg: NM_000546.6:5/11", Number: ".", Type: "String"},
+		"INTRON":          {Id: "INTRON", Description: "Intron number and total introns of transcript, FORMAT=Transcript:Intron/Total, eg: NM_000546.6:4/10", Number: ".", Type: "String"},
+		"CANONICAL":       {Id: "CANONICAL", Description: "Representative transcript of gene, FORMAT=Transcript:Source, Source: MANE_Select, MANE_Plus_Clinical, HGMD, Max_Length", Number: ".", Type: "String"},
+	}
+}
+
+func (this AnnoSnvParam) GetHeaderInfos(annotator *anno.Annotator) (map[string]*vcfgo.Info, []string, error) {
+	infos := this.GeneHeaderInfos()
+	ldbInfos, err := annotator.LevelDBHeaderInfos()
+	if err != nil {
+		return infos, []string{}, err
+	}
+	for _, info := range ldbInfos {
+		infos[info.Id] = info
+	}
+	for _, positionBased := range annotator.ScoreDBs {
+		infos[positionBased.Key] = positionBased.HeaderInfo()
 	}
 	for _, fbFile := range this.FilterBaseds {
 		fbTbx, err := bix.New(fbFile)
@@ -128,22 +386,124 @@ func (this AnnoSnvParam) GetHeaderInfos() (map[string]*vcfgo.Info, []string, err
 			}
 		}
 	}
-	dbnames := make([]string, len(this.RegionBaseds))
-	for i, rbFile := range this.RegionBaseds {
-		dbname := strings.Split(path.Base(rbFile), ".")[0]
-		dbnames[i] = dbname
-		infos[dbname] = &vcfgo.Info{Id: dbname, Description: dbname, Number: ".", Type: "String"}
+	dbnames := make([]string, 0)
+	for i, dbname := range annotator.DBNames {
+		for _, info := range annotator.Schemas[i].HeaderInfos() {
+			dbnames = append(dbnames, info.Id)
+			infos[info.Id] = info
+		}
+		dbnames = append(dbnames, anno.OverlapKey(dbname))
+		infos[anno.OverlapKey(dbname)] = OverlapHeaderInfo(dbname)
+	}
+	for _, geneBased := range annotator.GeneDBs {
+		for _, info := range geneBased.HeaderInfos() {
+			dbnames = append(dbnames, info.Id)
+			infos[info.Id] = info
+		}
 	}
 	return infos, dbnames, nil
 }
 
+// AnnoMNVs 合并注释同一单倍型上同一密码子中的SNP，结果以MNV_*字段写入每个原始记录
+func (this AnnoSnvParam) AnnoMNVs(snvs []*pkg.SNV, annotator *anno.Annotator, annoResult map[string]anno.SnvResult) error {
+	codons := make(map[string][]string)
+	for _, snv := range snvs {
+		if snv.Type() != pkg.VType_SNP {
+			continue
+		}
+		var err error
+		codons[snv.PK()], err = annotator.Codons(snv)
+		if err != nil {
+			return err
+		}
+	}
+	for _, group := range pkg.GroupMNVs(snvs, codons) {
+		mnv, err := pkg.NewMNV(group, annotator.Genome())
+		if err != nil {
+			return err
+		}
+		mnvResult, err := annotator.AnnotateGene(mnv)
+		if err != nil {
+			return err
+		}
+		for _, snv := range group {
+			result := annoResult[snv.PK()]
+			result.MNVs = append(result.MNVs, anno.MNVResult{Name: mnv.MNVName(), SnvResult: mnvResult})
+			annoResult[snv.PK()] = result
+		}
+	}
+	return nil
+}
+
+// NormalizeSnv 检查REF并左对齐变异，REF与参考基因组不一致时标记REF_MISMATCH
+func (this AnnoSnvParam) NormalizeSnv(snv *pkg.SNV, genome *faidx.Faidx) error {
+	ok, err := snv.CheckRef(genome)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if snv.Filter == "" || snv.Filter == "." || snv.Filter == "PASS" {
+			snv.Filter = "REF_MISMATCH"
+		} else {
+			snv.Filter += ";REF_MISMATCH"
+		}
+		return snv.Info().Set("REF_MISMATCH", true)
+	}
+	_, err = snv.Normalize(genome)
+	return err
+}
+
+// Annotatable 变异所在染色体是否需要注释
+func (this AnnoSnvParam) Annotatable(chrom string) bool {
+	return this.contigs.Allow(chrom) && (this.Chrom == "" || chrom == this.Chrom)
+}
+
+// WriteSnv 按输出格式写出变异及其注释结果，未注释的记录仅在vcf及csq格式中原样输出
+func (this AnnoSnvParam) WriteSnv(writer io.Writer, vcfWriter *vcfgo.Writer, snv *pkg.SNV, result anno.SnvResult, annotated bool, dbKeys []string) error {
+	var err error
+	if !annotated {
+		if vcfWriter != nil {
+			vcfWriter.WriteVariant(&snv.Variant)
+		}
+		return nil
+	}
+	switch this.OutputFormat {
+	case "tsv":
+		return this.WriteTsv(writer, snv, result, dbKeys)
+	case "ndjson":
+		return this.WriteJSON(writer, snv, result)
+	case "csq":
+		err = snv.Info().Set("CSQ", this.CSQ(snv, result, dbKeys))
+		if err != nil {
+			return err
+		}
+	}
+	whiteList := []string{"CONSEQUENCE", "DETAIL", "EVENT", "GENE", "GENE_ID", "REGION"}
+	for id, val := range result.Info() {
+		idx := sort.SearchStrings(whiteList, id)
+		if (idx < len(whiteList) && whiteList[idx] == id) || (val != "" && val != ".") {
+			err = snv.Info().Set(id, val)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	vcfWriter.WriteVariant(&snv.Variant)
+	return nil
+}
+
 func (this AnnoSnvParam) Run() error {
-	// 读取GeneID信息
-	log.Printf("Read Gene: %s ...", this.Gene)
-	err := pkg.InitGeneSymbolToID(this.Gene)
+	opts, err := this.Options()
 	if err != nil {
 		return err
 	}
+	// 打开GenePred、Genome及数据库
+	log.Printf("Open TABIX Handle and Genome Faidx ...")
+	annotator, err := anno.NewAnnotator(opts)
+	if err != nil {
+		return err
+	}
+	defer annotator.Close()
 	// 打开变异输入文件
 	log.Printf("Read AnnoInput: %s ...", this.Input)
 	reader, err := pkg.NewIOReader(this.Input)
@@ -157,113 +517,102 @@ func (this AnnoSnvParam) Run() error {
 	}
 	defer vcfReader.Close()
 	vcfHeader := vcfReader.Header
-	keys := make([]string, 0)
-	snvsMap := make(map[string][]*pkg.SNV)
-	for variant := vcfReader.Read(); variant != nil; variant = vcfReader.Read() {
-		snv := &pkg.SNV{Variant: *variant}
-		chrom := variant.Chrom()
-		if len(chrom) > 5 || (this.Chrom != "" && chrom != this.Chrom) {
-			continue
-		}
-		key := fmt.Sprintf("%s.%d", chrom, snv.Pos/uint64(pkg.FilterBasedBucketSize))
-		if snvs, ok := snvsMap[key]; !ok {
-			snvsMap[key] = []*pkg.SNV{snv}
-			keys = append(keys, key)
-		} else {
-			snvsMap[key] = append(snvs, snv)
-		}
-	}
+	vcfHeader.Infos["MULTIALLELIC"] = &vcfgo.Info{Id: "MULTIALLELIC", Description: "Original multi-allelic site split by ALT, FORMAT=Chrom:Pos:Ref/Alt1/Alt2", Number: "1", Type: "String"}
+	vcfHeader.Infos["OLD_VARIANT"] = &vcfgo.Info{Id: "OLD_VARIANT", Description: "Original variant before normalization, FORMAT=Chrom:Pos:Ref/Alt", Number: "1", Type: "String"}
+	vcfHeader.Infos["REF_MISMATCH"] = &vcfgo.Info{Id: "REF_MISMATCH", Description: "REF does not match the reference genome", Number: "0", Type: "Flag"}
+	vcfHeader.Filters["REF_MISMATCH"] = "REF does not match the reference genome"
 	// VcfHeaderInfo
-	infos, dbnames, err := this.GetHeaderInfos()
+	infos, dbnames, err := this.GetHeaderInfos(annotator)
 	if err != nil {
 		return err
 	}
 	for id, info := range infos {
 		vcfHeader.Infos[id] = info
 	}
-	// 打开Genome
-	log.Printf("Open Genome Faidx ...")
-	genome, err := faidx.New(this.Genome)
-	if err != nil {
-		return err
-	}
-	defer genome.Close()
-	// 打开GenePred
-	log.Printf("Open TABIX Handle ...")
-	gpeTbx, err := bix.New(this.GenePred)
+	// 打开输出句柄
+	log.Printf("Write to %s ...", this.Output)
+	writer, err := pkg.NewIOWriter(this.Output)
 	if err != nil {
 		return err
 	}
-	defer gpeTbx.Close()
-	// 打开FilterBaseds
-	fbTbxs := make([]*bix.Bix, len(this.FilterBaseds))
-	for i, fbFile := range this.FilterBaseds {
-		fbTbxs[i], err = bix.New(fbFile)
-		if err != nil {
-			return err
+	defer writer.Close()
+	// tsv输出所有数据库字段，csq仅输出FilterBased数据库字段
+	var vcfWriter *vcfgo.Writer
+	dbKeys := make([]string, 0)
+	geneInfos := this.GeneHeaderInfos()
+	for id := range infos {
+		if _, ok := geneInfos[id]; !ok && (this.OutputFormat == "tsv" || pkg.FindArr(dbnames, id) < 0) {
+			dbKeys = append(dbKeys, id)
 		}
-		defer fbTbxs[i].Close()
 	}
-	// 打开RegionBaseds
-	rbTbxs := make([]*bix.Bix, len(this.RegionBaseds))
-	for i, rbFile := range this.RegionBaseds {
-		rbTbxs[i], err = bix.New(rbFile)
+	sort.Strings(dbKeys)
+	if this.OutputFormat == "tsv" {
+		fmt.Fprintf(writer, "%s\n", strings.Join(append(TsvHeader, dbKeys...), "\t"))
+	} else if this.OutputFormat != "ndjson" {
+		if this.OutputFormat == "csq" {
+			vcfHeader.Infos["CSQ"] = &vcfgo.Info{Id: "CSQ", Description: "Consequence annotations from OpenAnno. Format: " + strings.Join(append(CsqFields, dbKeys...), "|"), Number: ".", Type: "String"}
+		}
+		vcfWriter, err = vcfgo.NewWriter(writer, vcfHeader)
 		if err != nil {
 			return err
 		}
-		defer rbTbxs[i].Close()
 	}
-	// 打开输出句柄
-	log.Printf("Write to %s ...", this.Output)
-	writer, err := pkg.NewIOWriter(this.Output)
-	if err != nil {
-		return err
-	}
-	defer writer.Close()
-	vcfWriter, err := vcfgo.NewWriter(writer, vcfHeader)
-	// 开始注释
+	// 开始注释：读取、注释、按输入顺序输出并行进行，window限制内存中的批次数
 	log.Println("Run Annotating ...")
-	whiteList := []string{"GENE", "GENE_ID", "EVENT", "REGION", "DETAIL"}
-	for _, key := range keys {
-		log.Printf("Run Annotating %s ...", key)
-		snvs, ok := snvsMap[key]
-		if !ok {
-			continue
-		}
-		fbTbxs2 := make([]*bix.Bix, 0)
-		for _, fbDir := range this.FilterBasedDirs {
-			fbFile := fmt.Sprintf("%s/%s.vcf.gz", fbDir, key)
-			_, err := os.Stat(fbFile)
-			if os.IsNotExist(err) {
-				continue
-			}
-			fbTbx, err := bix.New(fbFile)
-			if err != nil {
-				return err
-			}
-			fbTbxs2 = append(fbTbxs2, fbTbx)
-		}
-		annoResult, err := this.RunAnno(snvs, gpeTbx, append(fbTbxs, fbTbxs2...), rbTbxs, dbnames, genome)
-		for _, fbTbx := range fbTbxs2 {
-			fbTbx.Close()
+	window := make(chan struct{}, 2*(this.Concurrency+1))
+	chunks := make(chan *snvChunk, cap(window))
+	results := make(chan *snvChunk, cap(window))
+	done := make(chan struct{})
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- this.ReadChunks(vcfReader, annotator.Genome(), chunks, window, done)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i <= this.Concurrency; i++ {
+		wg.Add(1)
+		go this.AnnoChunks(annotator, chunks, results, &wg)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	// 出错后停止读取，等待正在注释的批次结束后返回
+	stop := func(e error) {
+		if err == nil {
+			err = e
+			close(done)
 		}
+	}
+	err = nil
+	pending := make(map[int]*snvChunk)
+	next := 0
+	for chunk := range results {
 		if err != nil {
-			return err
+			continue
+		}
+		if chunk.err != nil {
+			stop(chunk.err)
+			continue
 		}
-		for _, snv := range snvs {
-			for id, val := range annoResult[snv.PK()] {
-				idx := sort.SearchStrings(whiteList, id)
-				if (idx < len(whiteList) && whiteList[idx] == id) || (val != "" && val != ".") {
-					err = snv.Info().Set(id, val)
-					if err != nil {
-						return err
-					}
+		pending[chunk.index] = chunk
+		for chunk, ok := pending[next]; ok && err == nil; chunk, ok = pending[next] {
+			for _, snv := range chunk.records {
+				result, annotated := chunk.results[snv.PK()]
+				e := this.WriteSnv(writer, vcfWriter, snv, result, annotated, dbKeys)
+				if e != nil {
+					stop(e)
+					break
 				}
 			}
-			vcfWriter.WriteVariant(&snv.Variant)
+			delete(pending, next)
+			next++
+			<-window
 		}
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+	return <-readErr
 }
 
 func NewAnnoSnvCmd() *cobra.Command {
@@ -276,15 +625,36 @@ func NewAnnoSnvCmd() *cobra.Command {
 			param.GenePred, _ = cmd.Flags().GetString("genepred")
 			param.Genome, _ = cmd.Flags().GetString("genome")
 			param.Gene, _ = cmd.Flags().GetString("gene")
+			param.Protein, _ = cmd.Flags().GetString("protein")
+			param.Build, _ = cmd.Flags().GetString("build")
 			param.Output, _ = cmd.Flags().GetString("output")
 			param.AAshort, _ = cmd.Flags().GetBool("aashort")
 			param.Exon, _ = cmd.Flags().GetBool("exon")
 			param.FilterBaseds, _ = cmd.Flags().GetStringArray("filterbaseds")
 			param.RegionBaseds, _ = cmd.Flags().GetStringArray("regionbaseds")
+			param.GeneBaseds, _ = cmd.Flags().GetStringArray("genebaseds")
+			param.Scores, _ = cmd.Flags().GetStringArray("score")
 			param.FilterBasedDirs, _ = cmd.Flags().GetStringArray("filterbased_dirs")
+			param.FilterBasedLevelDBs, _ = cmd.Flags().GetStringArray("filterbased_leveldb")
 			param.Overlap, _ = cmd.Flags().GetFloat64("overlap")
+			param.OverlapMode, _ = cmd.Flags().GetString("overlap_mode")
+			param.OverlapDBs, _ = cmd.Flags().GetStringArray("overlap_db")
 			param.Concurrency, _ = cmd.Flags().GetInt("concurrency")
 			param.Chrom, _ = cmd.Flags().GetString("chrom")
+			param.Contigs, _ = cmd.Flags().GetStringSlice("contigs")
+			param.PassThrough, _ = cmd.Flags().GetBool("passthrough")
+			param.SpliceSite, _ = cmd.Flags().GetInt("splice_site")
+			param.SpliceRegionExon, _ = cmd.Flags().GetInt("splice_region_exon")
+			param.SpliceRegionIntron, _ = cmd.Flags().GetInt("splice_region_intron")
+			param.Polypyrimidine, _ = cmd.Flags().GetInt("polypyrimidine")
+			param.MaxEntScan, _ = cmd.Flags().GetBool("maxentscan")
+			param.MaxEntScanDir, _ = cmd.Flags().GetString("maxentscan_dir")
+			param.UpDownStream, _ = cmd.Flags().GetInt("updownstream")
+			param.OutputFormat, _ = cmd.Flags().GetString("output_format")
+			param.TransMode, _ = cmd.Flags().GetString("transcript_mode")
+			param.RepTrans, _ = cmd.Flags().GetString("reptrans")
+			param.Normalize, _ = cmd.Flags().GetBool("normalize")
+			param.MNV, _ = cmd.Flags().GetBool("mnv")
 			err := param.Valid()
 			if err != nil {
 				cmd.Help()
@@ -300,14 +670,35 @@ func NewAnnoSnvCmd() *cobra.Command {
 	cmd.Flags().StringP("genepred", "d", "", "Input GenePred File")
 	cmd.Flags().StringP("genome", "G", "", "Input Genome Fasta File")
 	cmd.Flags().StringP("gene", "g", "", "Input Gene Symbol To ID File")
+	cmd.Flags().StringP("protein", "P", "", "Input Transcript To Protein File from 'pre gene', provides versioned transcript and protein accessions")
+	cmd.Flags().StringP("build", "b", "GRCh38", "Parameter Genome Build, GRCh37 or GRCh38")
 	cmd.Flags().StringP("output", "o", "", "AnnoOutput File")
 	cmd.Flags().BoolP("aashort", "a", false, "Parameter Is AA Short")
 	cmd.Flags().BoolP("exon", "e", false, "Parameter Is Exon")
 	cmd.Flags().StringArrayP("filterbaseds", "f", []string{}, "Input FilterBased Database File")
-	cmd.Flags().StringArrayP("regionbaseds", "r", []string{}, "Input RegionBased Database File")
+	cmd.Flags().StringArrayP("regionbaseds", "r", []string{}, "Input RegionBased Database File, output columns are set by FILE.schema if exists, default: column 4")
+	cmd.Flags().StringArrayP("genebaseds", "B", []string{}, "Input GeneBased Database File, TSV with header, first column is GeneID(Entrez ID) or Gene Symbol")
+	cmd.Flags().StringArray("score", []string{}, "Input PositionBased Score File, bigWig(.bw) or bgzipped bedGraph with tabix index, FORMAT=[Key[:Aggregate]=]File, Aggregate: mean(default) or max over deleted bases, eg: phyloP:max=hg38.phyloP100way.bw")
 	cmd.Flags().StringArrayP("filterbased_dirs", "F", []string{}, "Input FilterBased Directory")
-	cmd.Flags().Float64P("overlap", "l", 0.7, "Parameter Database Name")
+	cmd.Flags().StringArray("filterbased_leveldb", []string{}, "Input FilterBased LevelDB Directory from 'pre leveldb', exact match on Chrom:Pos:Ref:Alt with shared bases trimmed")
+	cmd.Flags().Float64P("overlap", "l", 0.7, "Parameter Minimum Overlap Fraction of RegionBased Database")
+	cmd.Flags().String("overlap_mode", "query", "Parameter Overlap Mode of RegionBased Database, query, target, reciprocal, jaccard or any")
+	cmd.Flags().StringArray("overlap_db", []string{}, "Parameter Overlap of a RegionBased Database, FORMAT=DBName=Mode[:Fraction], eg: dgv=reciprocal:0.5")
 	cmd.Flags().IntP("concurrency", "c", 4, "Parameter Concurrency Numbers")
 	cmd.Flags().StringP("chrom", "m", "", "Chromosome")
+	cmd.Flags().StringSlice("contigs", []string{}, "Parameter Contigs to Annotate, comma separated, default 1-22, X, Y, M, MT with or without chr prefix, 'all' for every contig")
+	cmd.Flags().Bool("passthrough", false, "Parameter Write Records of Contigs not Annotated Unchanged in Input Order, vcf and csq only")
+	cmd.Flags().Int("splice_site", 2, "Parameter Splice Donor/Acceptor Site Length in Intron")
+	cmd.Flags().Int("splice_region_exon", 3, "Parameter Splice Region Length in Exon")
+	cmd.Flags().Int("splice_region_intron", 8, "Parameter Splice Region Length in Intron")
+	cmd.Flags().Int("polypyrimidine", 17, "Parameter Polypyrimidine Tract Length Upstream of Acceptor")
+	cmd.Flags().Bool("maxentscan", false, "Parameter Score Splice Sites with MaxEntScan")
+	cmd.Flags().String("maxentscan_dir", "", "Input MaxEntScan Directory with me2x5 and splicemodels/, required for --maxentscan unless the models are embedded at build time (pkg/data/maxentscan)")
+	cmd.Flags().Int("updownstream", 5000, "Parameter Upstream/Downstream Length of Transcript")
+	cmd.Flags().String("output_format", "vcf", "Parameter Output Format, vcf, tsv(one row per transcript), csq(vcf with VEP-style CSQ) or ndjson(one JSON object per variant)")
+	cmd.Flags().String("transcript_mode", "all", "Parameter Transcript Mode, all, rep, mane or pick(one transcript per gene)")
+	cmd.Flags().String("reptrans", "", "Input Representative Transcript File from 'tools rt', MANE tags of GTF/GFF3 gene models from 'pre gtf' are used when absent")
+	cmd.Flags().Bool("normalize", false, "Parameter Check REF and Left-normalize Variants against Genome, off by default")
+	cmd.Flags().Bool("mnv", false, "Parameter Annotate Phased Neighbour SNPs (FORMAT/GT, PS) in the Same Codon Jointly")
 	return cmd
 }
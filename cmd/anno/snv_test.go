@@ -0,0 +1,31 @@
+package anno
+
+import (
+	"open-anno/pkg"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestOverlapValid(t *testing.T) {
+	tests := []struct {
+		overlap float64
+		valid   bool
+	}{
+		{0, true},
+		{0.7, true},
+		{1, true},
+		{-0.1, false},
+		{1.5, false},
+	}
+	validate := validator.New()
+	validate.RegisterValidation("pathexists", pkg.CheckPathExists)
+	validate.RegisterValidation("pathsexists", pkg.CheckPathsExists)
+	for _, test := range tests {
+		snvErr := validate.StructPartial(AnnoSnvParam{Overlap: test.overlap}, "Overlap")
+		cnvErr := validate.StructPartial(AnnoCnvParam{Overlap: test.overlap}, "Overlap")
+		if (snvErr == nil) != test.valid || (cnvErr == nil) != test.valid {
+			t.Errorf("%v: got %v %v, want valid %v", test.overlap, snvErr, cnvErr, test.valid)
+		}
+	}
+}
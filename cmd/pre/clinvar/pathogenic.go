@@ -6,7 +6,6 @@ import (
 
 	"fmt"
 	"open-anno/anno"
-	"open-anno/anno/gene"
 	"open-anno/pkg"
 	"os"
 	"path"
@@ -14,7 +13,6 @@ import (
 	"strings"
 
 	"github.com/brentp/bix"
-	"github.com/brentp/faidx"
 	"github.com/brentp/vcfgo"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
@@ -35,8 +33,6 @@ type PrePathogenicParam struct {
 func (this PrePathogenicParam) Valid() error {
 	this.GenePredIndex = this.GenePred + ".tbi"
 	this.GenomeIndex = this.Genome + ".fai"
-	pkg.IS_EXON_REGION = this.Exon
-	gene.AA_SHORT = this.AAshort
 	validate := validator.New()
 	validate.RegisterValidation("pathexists", pkg.CheckPathExists)
 	err := validate.Struct(this)
@@ -60,7 +56,7 @@ func (this PrePathogenicParam) GetVariants() ([]*pkg.SNV, error) {
 	}
 	defer vcfReader.Close()
 	for row := vcfReader.Read(); row != nil; row = vcfReader.Read() {
-		snv := &pkg.SNV{*row}
+		snv := &pkg.SNV{Variant: *row}
 		snv.Chromosome = "chr" + strings.ReplaceAll(snv.Chromosome, "MT", "M")
 		if len(snv.Ref()) == 1 && len(snv.Alt()[0]) == 1 {
 			val, err := snv.Info().Get("CLNREVSTAT")
@@ -105,17 +101,32 @@ func (this PrePathogenicParam) GetVariants() ([]*pkg.SNV, error) {
 	return snvs, nil
 }
 
-func (this PrePathogenicParam) RunAnno(snvs []*pkg.SNV, gpeTbx *bix.Bix, genome *faidx.Faidx) (map[string]map[string]any, error) {
+// NewAnnotator 创建仅注释基因功能的注释器
+func (this PrePathogenicParam) NewAnnotator() (*anno.Annotator, error) {
+	var err error
+	opts := anno.DefaultOptions()
+	opts.GenePred, opts.Genome = this.GenePred, this.Genome
+	opts.AAShort, opts.ExonRegion = this.AAshort, this.Exon
+	log.Printf("Read Gene: %s ...", this.Gene)
+	opts.GeneSymbols, err = pkg.ReadGeneSymbols(this.Gene)
+	if err != nil {
+		return &anno.Annotator{}, err
+	}
+	log.Printf("Open TABIX Handle ...")
+	return anno.NewAnnotator(opts)
+}
+
+func (this PrePathogenicParam) RunAnno(snvs []*pkg.SNV, annotator *anno.Annotator) (map[string]map[string]any, error) {
 	snvChan := make(chan *pkg.SNV, len(snvs))
 	for _, snv := range snvs {
 		snvChan <- snv
 	}
 	close(snvChan)
 	var wg sync.WaitGroup
-	resChan := make(chan anno.AnnoInfo, len(snvs))
+	resChan := make(chan anno.SnvResult, len(snvs))
 	for i := 0; i <= 80; i++ {
 		wg.Add(1)
-		go anno.AnnoSnvWorker(snvChan, gpeTbx, []*bix.Bix{}, []*bix.Bix{}, []string{}, genome, 0.7, resChan, &wg)
+		go annotator.SnvWorker(snvChan, []*bix.Bix{}, resChan, &wg)
 	}
 	go func() {
 		wg.Wait()
@@ -126,33 +137,22 @@ func (this PrePathogenicParam) RunAnno(snvs []*pkg.SNV, gpeTbx *bix.Bix, genome
 		if res.Error != nil {
 			return results, res.Error
 		}
-		results[res.PK] = res.Data
+		results[res.PK] = res.Info()
 	}
 	return results, nil
 }
 
 func (this PrePathogenicParam) Run() error {
-	log.Printf("Read Gene: %s ...", this.Gene)
-	err := pkg.InitGeneSymbolToID(this.Gene)
-	if err != nil {
-		return err
-	}
-	log.Printf("Open TABIX Handle ...")
-	gpeTbx, err := bix.New(this.GenePred)
-	if err != nil {
-		return err
-	}
-	defer gpeTbx.Close()
-	// 打开Genome
-	genome, err := faidx.New(this.Genome)
+	annotator, err := this.NewAnnotator()
 	if err != nil {
 		return err
 	}
+	defer annotator.Close()
 	snvs, err := this.GetVariants()
 	if err != nil {
 		return err
 	}
-	annoResult, err := this.RunAnno(snvs, gpeTbx, genome)
+	annoResult, err := this.RunAnno(snvs, annotator)
 	if err != nil {
 		return err
 	}
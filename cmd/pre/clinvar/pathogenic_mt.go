@@ -8,8 +8,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/brentp/bix"
-	"github.com/brentp/faidx"
 	"github.com/spf13/cobra"
 )
 
@@ -32,27 +30,16 @@ func (this PrePathogenicMTParam) GetVariants() ([]*pkg.SNV, error) {
 }
 
 func (this PrePathogenicMTParam) Run() error {
-	log.Printf("Read Gene: %s ...", this.Gene)
-	err := pkg.InitGeneSymbolToID(this.Gene)
-	if err != nil {
-		return err
-	}
-	log.Printf("Open TABIX Handle ...")
-	gpeTbx, err := bix.New(this.GenePred)
-	if err != nil {
-		return err
-	}
-	defer gpeTbx.Close()
-	// 打开Genome
-	genome, err := faidx.New(this.Genome)
+	annotator, err := this.NewAnnotator()
 	if err != nil {
 		return err
 	}
+	defer annotator.Close()
 	snvs, err := this.GetVariants()
 	if err != nil {
 		return err
 	}
-	annoResult, err := this.RunAnno(snvs, gpeTbx, genome)
+	annoResult, err := this.RunAnno(snvs, annotator)
 	if err != nil {
 		return err
 	}
@@ -7,6 +7,7 @@ import (
 	"open-anno/pkg"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -18,6 +19,7 @@ type PreGeneParam struct {
 	GeneInfo    string `validate:"required,pathexists"`
 	GenePred    string `validate:"required,pathexists"`
 	Output      string `validate:"required"`
+	Protein     string
 }
 
 func (this PreGeneParam) Valid() error {
@@ -28,6 +30,12 @@ func (this PreGeneParam) Valid() error {
 		return err
 	}
 	outdir := path.Dir(this.Output)
+	if this.Protein != "" {
+		err = os.MkdirAll(path.Dir(this.Protein), 0666)
+		if err != nil {
+			return err
+		}
+	}
 	return os.MkdirAll(outdir, 0666)
 }
 
@@ -48,6 +56,29 @@ func (this PreGeneParam) ReadGene2Refseq() (map[string]string, error) {
 	return transToId, err
 }
 
+func (this PreGeneParam) ReadTransToProtein() (map[string]string, error) {
+	transToProtein := make(map[string]string)
+	reader, err := pkg.NewIOReader(this.Gene2Refseq)
+	if err != nil {
+		return transToProtein, err
+	}
+	defer reader.Close()
+	scanner := pkg.NewCSVScanner(reader)
+	for scanner.Scan() {
+		row := scanner.Row()
+		trans := row["RNA_nucleotide_accession.version"]
+		protein := row["protein_accession.version"]
+		// 非编码转录本同样输出，用于获取带版本号的转录本登录号
+		if trans == "-" {
+			continue
+		}
+		if old, ok := transToProtein[trans]; !ok || old == "-" {
+			transToProtein[trans] = protein
+		}
+	}
+	return transToProtein, err
+}
+
 func (this PreGeneParam) ReadNCBIGeneInfo() (map[string]map[string]string, map[string]map[string]string, error) {
 	symbolToId := make(map[string]map[string]string)
 	synonymsToId := make(map[string]map[string]string)
@@ -142,6 +173,27 @@ func (this PreGeneParam) Run() error {
 			fmt.Fprintf(writer, "%s\t%s\t%s\n", chrom, symbol, entrezId)
 		}
 	}
+	if this.Protein != "" {
+		log.Printf("Read NCBI Gene2Refseq Protein from %s ...", this.Gene2Refseq)
+		transToProtein, err := this.ReadTransToProtein()
+		if err != nil {
+			return err
+		}
+		protWriter, err := pkg.NewIOWriter(this.Protein)
+		if err != nil {
+			return err
+		}
+		defer protWriter.Close()
+		fmt.Fprint(protWriter, "Transcript\tProtein\n")
+		transcripts := make([]string, 0, len(transToProtein))
+		for trans := range transToProtein {
+			transcripts = append(transcripts, trans)
+		}
+		sort.Strings(transcripts)
+		for _, trans := range transcripts {
+			fmt.Fprintf(protWriter, "%s\t%s\n", trans, transToProtein[trans])
+		}
+	}
 	return nil
 }
 
@@ -155,6 +207,7 @@ func NewGeneCmd() *cobra.Command {
 			param.GeneInfo, _ = cmd.Flags().GetString("geneinfo")
 			param.GenePred, _ = cmd.Flags().GetString("genepred")
 			param.Output, _ = cmd.Flags().GetString("output")
+			param.Protein, _ = cmd.Flags().GetString("protein")
 			err := param.Valid()
 			if err != nil {
 				cmd.Help()
@@ -170,5 +223,6 @@ func NewGeneCmd() *cobra.Command {
 	cmd.Flags().StringP("gene2refseq", "r", "", "Input NCBI Gene2Refseq File")
 	cmd.Flags().StringP("geneinfo", "i", "", "Input NCBI GeneInfo File")
 	cmd.Flags().StringP("output", "o", "", "Output File")
+	cmd.Flags().StringP("protein", "P", "", "Output Transcript To Protein File, non-coding transcripts are kept for their versioned accessions")
 	return cmd
 }
@@ -0,0 +1,72 @@
+package pre
+
+import (
+	"open-anno/anno/gene"
+	"open-anno/pkg"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPreGeneVersionedAccessions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"gene2refseq": "#tax_id\tGeneID\tRNA_nucleotide_accession.version\tprotein_accession.version\n" +
+			"9606\t7157\tNM_000546.6\tNP_000537.3\n" +
+			"9606\t7157\tNM_000546.6\tNP_000537.3\n" +
+			"9606\t100\tNR_024540.1\t-\n" +
+			"9606\t200\t-\tNP_000001.1\n",
+		"gene_info": "#tax_id\tGeneID\tSymbol\tSynonyms\tchromosome\n" +
+			"9606\t7157\tTP53\tP53\t17\n",
+		"genepred": "0\tNM_000546\tchr17\t-\t100\t200\t120\t180\t1\t100,\t200,\t0\tTP53\tcmpl\tcmpl\t0,\n" +
+			"0\tNR_024540\tchr17\t+\t100\t200\t200\t200\t1\t100,\t200,\t0\tWASH7P\tunk\tunk\t-1,\n" +
+			"0\tNM_000001.1\tchr17\t+\t100\t200\t120\t180\t1\t100,\t200,\t0\tGENE\tcmpl\tcmpl\t0,\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	param := PreGeneParam{
+		Gene2Refseq: path.Join(dir, "gene2refseq"),
+		GeneInfo:    path.Join(dir, "gene_info"),
+		GenePred:    path.Join(dir, "genepred"),
+		Output:      path.Join(dir, "gene.txt"),
+		Protein:     path.Join(dir, "protein.txt"),
+	}
+	if err := param.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	if err := param.Run(); err != nil {
+		t.Fatal(err)
+	}
+	accessions, err := pkg.ReadTransAccessions(param.Protein)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := gene.DefaultOptions()
+	options.Accessions = accessions
+	tests := []struct {
+		genePred   string
+		transcript string
+		protein    string
+		hgvsc      string
+	}{
+		{"0\tNM_000546\tchr17\t-\t100\t200\t120\t180\t1\t100,\t200,\t0\tTP53\tcmpl\tcmpl\t0,", "NM_000546.6", "NP_000537.3", "NM_000546.6:c.215C>G"},
+		{"0\tNR_024540\tchr17\t+\t100\t200\t200\t200\t1\t100,\t200,\t0\tWASH7P\tunk\tunk\t-1,", "NR_024540.1", "", "NR_024540.1:c.215C>G"},
+		{"0\tNM_000001.1\tchr17\t+\t100\t200\t120\t180\t1\t100,\t200,\t0\tGENE\tcmpl\tcmpl\t0,", "NM_000001.1", "", "NM_000001.1:c.215C>G"},
+		{"0\tNM_999999\tchr17\t+\t100\t200\t120\t180\t1\t100,\t200,\t0\tGENE\tcmpl\tcmpl\t0,", "NM_999999", "", "NM_999999:c.215C>G"},
+	}
+	for _, test := range tests {
+		trans, err := pkg.NewTranscript(test.genePred)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transAnno := options.NewTransAnno(trans)
+		transAnno.NAChange = "c.215C>G"
+		cnvTransAnno := options.NewCnvTransAnno(trans)
+		if transAnno.Transcript != test.transcript || transAnno.Protein != test.protein || transAnno.HGVSc() != test.hgvsc || cnvTransAnno.Transcript != test.transcript {
+			t.Errorf("%s: got %s %s %s %s, want %s %s %s", trans.Name, transAnno.Transcript, transAnno.Protein, transAnno.HGVSc(), cnvTransAnno.Transcript, test.transcript, test.protein, test.hgvsc)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package pre
+
+import (
+	"fmt"
+	"log"
+	"open-anno/pkg"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+type PreGeneModelParam struct {
+	Input  string `validate:"required,pathexists"`
+	Format string `validate:"omitempty,oneof=gtf gff3"`
+	Output string `validate:"required,endswith=.gz"`
+}
+
+func (this PreGeneModelParam) Valid() error {
+	validate := validator.New()
+	validate.RegisterValidation("pathexists", pkg.CheckPathExists)
+	err := validate.Struct(this)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path.Dir(this.Output), 0666)
+}
+
+func (this PreGeneModelParam) Run() error {
+	format := this.Format
+	if format == "" {
+		format = pkg.GeneModelFormat(this.Input)
+	}
+	log.Printf("Read %s from %s ...", strings.ToUpper(format), this.Input)
+	transcripts, err := pkg.ReadGeneModel(this.Input, format)
+	if err != nil {
+		return err
+	}
+	lines := make([]string, len(transcripts))
+	for i, trans := range transcripts {
+		lines[i] = trans.GenePredLine()
+	}
+	log.Printf("Write %d transcripts to %s ...", len(lines), this.Output)
+	return pkg.WriteTabix(this.Output, []string{}, lines, pkg.TabixGenePred)
+}
+
+func NewPreGeneModelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gtf",
+		Short: "Convert GTF/GFF3 to sorted and tabix indexed GenePred",
+		Run: func(cmd *cobra.Command, args []string) {
+			var param PreGeneModelParam
+			param.Input, _ = cmd.Flags().GetString("input")
+			param.Format, _ = cmd.Flags().GetString("format")
+			param.Output, _ = cmd.Flags().GetString("output")
+			err := param.Valid()
+			if err != nil {
+				cmd.Help()
+				log.Fatal(err)
+			}
+			err = param.Run()
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().StringP("input", "i", "", "Input GTF/GFF3 File, gzip supported")
+	cmd.Flags().StringP("format", "f", "", fmt.Sprintf("Input Format: %s or %s, detected from file name when empty", pkg.GeneModel_GTF, pkg.GeneModel_GFF3))
+	cmd.Flags().StringP("output", "o", "", "Output GenePred File, bgzip compressed and tabix indexed (.gz)")
+	return cmd
+}
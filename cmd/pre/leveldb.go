@@ -0,0 +1,148 @@
+package pre
+
+import (
+	"log"
+	"open-anno/anno/db"
+	"open-anno/pkg"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/brentp/faidx"
+	"github.com/brentp/vcfgo"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// LEVELDB_BATCH_SIZE 每次批量写入LevelDB的变异数
+const LEVELDB_BATCH_SIZE = 10000
+
+type PreLevelDBParam struct {
+	Input  string `validate:"required,pathexists"`
+	Genome string `validate:"omitempty,pathexists"`
+	Output string `validate:"required"`
+}
+
+func (this PreLevelDBParam) Valid() error {
+	validate := validator.New()
+	validate.RegisterValidation("pathexists", pkg.CheckPathExists)
+	err := validate.Struct(this)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path.Dir(this.Output), 0666)
+}
+
+func (this PreLevelDBParam) Run() error {
+	reader, err := pkg.NewIOReader(this.Input)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	vcfReader, err := vcfgo.NewReader(reader, false)
+	if err != nil {
+		return err
+	}
+	defer vcfReader.Close()
+	ldb, err := leveldb.OpenFile(this.Output, nil)
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+	var genome *faidx.Faidx
+	if this.Genome != "" {
+		genome, err = faidx.New(this.Genome)
+		if err != nil {
+			return err
+		}
+	}
+	// VcfHeaderInfo
+	infos := make([]*vcfgo.Info, 0)
+	for _, info := range vcfReader.Header.Infos {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Id < infos[j].Id })
+	err = db.PutHeaderLevelDB(ldb, infos)
+	if err != nil {
+		return err
+	}
+	// 多等位位点按ALT拆分，与anno snv --normalize相同的方式左对齐后分别写入
+	batch := new(leveldb.Batch)
+	count := 0
+	for row := vcfReader.Read(); row != nil; row = vcfReader.Read() {
+		alts := row.Alt()
+		for i, alt := range alts {
+			variant := *row
+			variant.Alternate = []string{alt}
+			annoInfo := make(map[string]any)
+			for _, key := range row.Info().Keys() {
+				val, err := row.Info().Get(key)
+				if err == nil {
+					annoInfo[key] = pkg.AlleleValue(val, vcfReader.Header.Infos[key], i, len(alts))
+				}
+			}
+			snv := &pkg.SNV{Variant: variant}
+			if genome != nil {
+				err = this.NormalizeSnv(snv, genome)
+				if err != nil {
+					return err
+				}
+			}
+			err = db.PutFilterBasedLevelDB(batch, snv, annoInfo)
+			if err != nil {
+				return err
+			}
+		}
+		if batch.Len() >= LEVELDB_BATCH_SIZE {
+			err = ldb.Write(batch, nil)
+			if err != nil {
+				return err
+			}
+			count += batch.Len()
+			log.Printf("Write %d variants ...", count)
+			batch.Reset()
+		}
+	}
+	return ldb.Write(batch, nil)
+}
+
+// NormalizeSnv 左对齐变异，REF与参考基因组不一致时保留原始变异；不修改共享的INFO
+func (this PreLevelDBParam) NormalizeSnv(snv *pkg.SNV, genome *faidx.Faidx) error {
+	ok, err := snv.CheckRef(genome)
+	if err != nil || !ok {
+		return err
+	}
+	pos, ref, alt, err := pkg.NormalizeAlleles(genome, snv.Chrom(), int(snv.Pos), snv.Ref(), snv.Alt()[0])
+	if err != nil {
+		return err
+	}
+	snv.Pos, snv.Reference, snv.Alternate = uint64(pos), ref, []string{alt}
+	return nil
+}
+
+func NewPreLevelDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "leveldb",
+		Short: "Prepare FilterBased LevelDB from VCF",
+		Run: func(cmd *cobra.Command, args []string) {
+			var param PreLevelDBParam
+			param.Input, _ = cmd.Flags().GetString("input")
+			param.Genome, _ = cmd.Flags().GetString("genome")
+			param.Output, _ = cmd.Flags().GetString("output")
+			err := param.Valid()
+			if err != nil {
+				cmd.Help()
+				log.Fatal(err)
+			}
+			err = param.Run()
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().StringP("input", "i", "", "Input FilterBased VCF File")
+	cmd.Flags().StringP("genome", "G", "", "Input Genome Fasta File, Left-normalize Variants as 'anno snv --normalize' when given")
+	cmd.Flags().StringP("output", "o", "", "Output LevelDB Directory")
+	return cmd
+}
@@ -0,0 +1,75 @@
+package pre
+
+import (
+	"fmt"
+	"open-anno/anno/db"
+	"open-anno/pkg"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/brentp/vcfgo"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestPreLevelDBRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	// chr1: G G G C A T T T T C A G G
+	seq := "GGGCATTTTCAGG"
+	genome := path.Join(dir, "genome.fa")
+	if err := os.WriteFile(genome, []byte(">chr1\n"+seq+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(genome+".fai", []byte(fmt.Sprintf("chr1\t%d\t6\t%d\t%d\n", len(seq), len(seq), len(seq)+1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := path.Join(dir, "db.vcf")
+	vcf := "##fileformat=VCFv4.2\n" +
+		"##INFO=<ID=AF,Number=A,Type=Float,Description=\"AF\">\n" +
+		"##INFO=<ID=NAME,Number=1,Type=String,Description=\"NAME\">\n" +
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n" +
+		"chr1\t7\t.\tTTTC\tTTC,TC\t.\t.\tAF=0.1,0.2;NAME=del\n" +
+		"chr1\t12\t.\tG\tA,C\t.\t.\tAF=0.3,0.4;NAME=snv\n"
+	if err := os.WriteFile(input, []byte(vcf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	param := PreLevelDBParam{Input: input, Genome: genome, Output: path.Join(dir, "db.ldb")}
+	if err := param.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	if err := param.Run(); err != nil {
+		t.Fatal(err)
+	}
+	ldb, err := leveldb.OpenFile(param.Output, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	tests := []struct {
+		pos      uint64
+		ref, alt string
+		af, name string
+	}{
+		{5, "AT", "A", "0.1", "del"},    // 左对齐后的缺失
+		{5, "ATT", "A", "0.2", "del"},   // 左对齐后的缺失
+		{5, "ATTT", "AT", "0.2", "del"}, // 未精简的查询
+		{12, "G", "A", "0.3", "snv"},
+		{12, "G", "C", "0.4", "snv"},
+		{7, "TT", "T", "", ""}, // 未左对齐的查询不匹配
+		{12, "G", "T", "", ""},
+	}
+	for _, test := range tests {
+		snv := &pkg.SNV{Variant: vcfgo.Variant{Chromosome: "chr1", Pos: test.pos, Reference: test.ref, Alternate: []string{test.alt}}}
+		annoInfo, err := db.AnnoFilterBasedLevelDB(snv, ldb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		af, name := "", ""
+		if len(annoInfo) > 0 {
+			af, name = fmt.Sprint(annoInfo["AF"]), fmt.Sprint(annoInfo["NAME"])
+		}
+		if af != test.af || name != test.name {
+			t.Errorf("%s: AF=%s NAME=%s, want AF=%s NAME=%s", snv.PK(), af, name, test.af, test.name)
+		}
+	}
+}
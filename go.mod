@@ -3,6 +3,7 @@ module open-anno
 go 1.18
 
 require (
+	github.com/biogo/hts v1.4.4
 	github.com/brentp/bix v0.0.0-20190718140914-00aa7a7f205d
 	github.com/brentp/faidx v0.0.0-20200301150453-c39eb85760d8
 	github.com/brentp/irelate v0.0.1
@@ -13,7 +14,6 @@ require (
 )
 
 require (
-	github.com/biogo/hts v1.4.4 // indirect
 	github.com/edsrzf/mmap-go v1.1.0 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
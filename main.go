@@ -27,10 +27,12 @@ func NewPreCmd() *cobra.Command {
 		Short: "Prepare database",
 	}
 	cmd.AddCommand(pre.NewGeneCmd())
+	cmd.AddCommand(pre.NewPreGeneModelCmd())
 
 	cmd.AddCommand(pre.NewPreGnomadCmd())
 	cmd.AddCommand(pre.NewPreDbnsfpCmd())
 	cmd.AddCommand(pre.NewSplitVCFCmd())
+	cmd.AddCommand(pre.NewPreLevelDBCmd())
 	cln := &cobra.Command{
 		Use:   "clinvar",
 		Short: "Prepare ClinVar Database",
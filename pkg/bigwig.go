@@ -0,0 +1,265 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	bigWigMagic    = 0x888FFC26
+	bptMagic       = 0x78CA8C91
+	cirTreeMagic   = 0x2468ACE0
+	bwSectionBed   = 1
+	bwSectionVar   = 2
+	bwSectionFixed = 3
+)
+
+// ScoreInterval 打分区间，0-based半开区间
+type ScoreInterval struct {
+	Start int
+	End   int
+	Score float64
+}
+
+// bwChrom bigWig中的染色体
+type bwChrom struct {
+	ID   uint32
+	Size uint32
+}
+
+// BigWig bigWig文件，仅读取原始数据，不使用zoom层级，Query可并发调用
+type BigWig struct {
+	file              *os.File
+	order             binary.ByteOrder
+	chroms            map[string]bwChrom
+	indexOffset       uint64
+	uncompressBufSize uint32
+}
+
+// IsBigWig 文件是否为bigWig格式，以后缀判断
+func IsBigWig(infile string) bool {
+	lower := strings.ToLower(infile)
+	return strings.HasSuffix(lower, ".bw") || strings.HasSuffix(lower, ".bigwig")
+}
+
+// OpenBigWig 打开bigWig文件，读取文件头及染色体B+树
+func OpenBigWig(infile string) (*BigWig, error) {
+	file, err := os.Open(infile)
+	if err != nil {
+		return nil, err
+	}
+	bw := &BigWig{file: file, chroms: make(map[string]bwChrom)}
+	header := make([]byte, 64)
+	if _, err = file.ReadAt(header, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header) == bigWigMagic:
+		bw.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == bigWigMagic:
+		bw.order = binary.BigEndian
+	default:
+		file.Close()
+		return nil, fmt.Errorf("not a bigWig file: %s", infile)
+	}
+	chromTreeOffset := bw.order.Uint64(header[8:])
+	bw.indexOffset = bw.order.Uint64(header[24:])
+	bw.uncompressBufSize = bw.order.Uint32(header[52:])
+	if err = bw.readChromTree(chromTreeOffset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return bw, nil
+}
+
+// Close 关闭文件
+func (this *BigWig) Close() error {
+	return this.file.Close()
+}
+
+// readAt 读取offset处的size个字节
+func (this *BigWig) readAt(offset uint64, size int) ([]byte, error) {
+	buf := make([]byte, size)
+	_, err := this.file.ReadAt(buf, int64(offset))
+	return buf, err
+}
+
+// readChromTree 读取染色体B+树
+func (this *BigWig) readChromTree(offset uint64) error {
+	header, err := this.readAt(offset, 32)
+	if err != nil {
+		return err
+	}
+	if this.order.Uint32(header) != bptMagic {
+		return fmt.Errorf("error chromosome B+ tree magic of bigWig")
+	}
+	keySize, valSize := int(this.order.Uint32(header[8:])), int(this.order.Uint32(header[12:]))
+	return this.readChromNode(offset+32, keySize, valSize)
+}
+
+// readChromNode 读取B+树节点，叶节点记录染色体名称、ID及长度
+func (this *BigWig) readChromNode(offset uint64, keySize, valSize int) error {
+	head, err := this.readAt(offset, 4)
+	if err != nil {
+		return err
+	}
+	isLeaf, count := head[0] == 1, int(this.order.Uint16(head[2:]))
+	itemSize := keySize + 8
+	if isLeaf {
+		itemSize = keySize + valSize
+	}
+	items, err := this.readAt(offset+4, count*itemSize)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		item := items[i*itemSize : (i+1)*itemSize]
+		if isLeaf {
+			name := string(bytes.TrimRight(item[:keySize], "\x00"))
+			this.chroms[name] = bwChrom{ID: this.order.Uint32(item[keySize:]), Size: this.order.Uint32(item[keySize+4:])}
+		} else if err = this.readChromNode(this.order.Uint64(item[keySize:]), keySize, valSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bwBlock R树叶节点指向的数据块
+type bwBlock struct {
+	offset uint64
+	size   uint64
+}
+
+// overlaps R树节点区间是否与查询区间重叠
+func (this *BigWig) overlaps(item []byte, chromID uint32, start, end int) bool {
+	startChrom, startBase := this.order.Uint32(item), this.order.Uint32(item[4:])
+	endChrom, endBase := this.order.Uint32(item[8:]), this.order.Uint32(item[12:])
+	if chromID < startChrom || chromID > endChrom {
+		return false
+	}
+	if chromID == startChrom && uint32(end) <= startBase {
+		return false
+	}
+	if chromID == endChrom && uint32(start) >= endBase {
+		return false
+	}
+	return true
+}
+
+// findBlocks 在R树中查找与查询区间重叠的数据块
+func (this *BigWig) findBlocks(offset uint64, chromID uint32, start, end int) ([]bwBlock, error) {
+	blocks := make([]bwBlock, 0)
+	head, err := this.readAt(offset, 4)
+	if err != nil {
+		return blocks, err
+	}
+	isLeaf, count := head[0] == 1, int(this.order.Uint16(head[2:]))
+	itemSize := 24
+	if isLeaf {
+		itemSize = 32
+	}
+	items, err := this.readAt(offset+4, count*itemSize)
+	if err != nil {
+		return blocks, err
+	}
+	for i := 0; i < count; i++ {
+		item := items[i*itemSize : (i+1)*itemSize]
+		if !this.overlaps(item, chromID, start, end) {
+			continue
+		}
+		if isLeaf {
+			blocks = append(blocks, bwBlock{offset: this.order.Uint64(item[16:]), size: this.order.Uint64(item[24:])})
+			continue
+		}
+		children, err := this.findBlocks(this.order.Uint64(item[16:]), chromID, start, end)
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, children...)
+	}
+	return blocks, nil
+}
+
+// readBlock 读取数据块中与查询区间重叠的打分区间
+func (this *BigWig) readBlock(block bwBlock, chromID uint32, start, end int) ([]ScoreInterval, error) {
+	intervals := make([]ScoreInterval, 0)
+	data, err := this.readAt(block.offset, int(block.size))
+	if err != nil {
+		return intervals, err
+	}
+	if this.uncompressBufSize > 0 {
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return intervals, err
+		}
+		data, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return intervals, err
+		}
+	}
+	for len(data) >= 24 {
+		secChrom, secStart := this.order.Uint32(data), int(this.order.Uint32(data[4:]))
+		step, span := int(this.order.Uint32(data[12:])), int(this.order.Uint32(data[16:]))
+		secType, count := data[20], int(this.order.Uint16(data[22:]))
+		data = data[24:]
+		itemSize := map[byte]int{bwSectionBed: 12, bwSectionVar: 8, bwSectionFixed: 4}[secType]
+		if itemSize == 0 || len(data) < count*itemSize {
+			return intervals, fmt.Errorf("error section of bigWig data block")
+		}
+		for i := 0; i < count; i++ {
+			item := data[i*itemSize:]
+			var interval ScoreInterval
+			switch secType {
+			case bwSectionBed:
+				interval = ScoreInterval{Start: int(this.order.Uint32(item)), End: int(this.order.Uint32(item[4:])), Score: float64(math.Float32frombits(this.order.Uint32(item[8:])))}
+			case bwSectionVar:
+				interval.Start = int(this.order.Uint32(item))
+				interval.End, interval.Score = interval.Start+span, float64(math.Float32frombits(this.order.Uint32(item[4:])))
+			case bwSectionFixed:
+				interval.Start = secStart + i*step
+				interval.End, interval.Score = interval.Start+span, float64(math.Float32frombits(this.order.Uint32(item)))
+			}
+			if secChrom == chromID && interval.Start < end && interval.End > start {
+				intervals = append(intervals, interval)
+			}
+		}
+		data = data[count*itemSize:]
+	}
+	return intervals, nil
+}
+
+// Query 查询与区间[start, end)重叠的打分区间，染色体不存在时返回空结果
+func (this *BigWig) Query(chrom string, start, end int) ([]ScoreInterval, error) {
+	intervals := make([]ScoreInterval, 0)
+	bwc, ok := this.chroms[chrom]
+	if !ok {
+		return intervals, nil
+	}
+	header, err := this.readAt(this.indexOffset, 48)
+	if err != nil {
+		return intervals, err
+	}
+	if this.order.Uint32(header) != cirTreeMagic {
+		return intervals, fmt.Errorf("error R tree magic of bigWig")
+	}
+	blocks, err := this.findBlocks(this.indexOffset+48, bwc.ID, start, end)
+	if err != nil {
+		return intervals, err
+	}
+	for _, block := range blocks {
+		blockIntervals, err := this.readBlock(block, bwc.ID, start, end)
+		if err != nil {
+			return intervals, err
+		}
+		intervals = append(intervals, blockIntervals...)
+	}
+	return intervals, nil
+}
@@ -0,0 +1,43 @@
+package pkg
+
+import "fmt"
+
+// CONTIGS_ALL 注释所有染色体
+const CONTIGS_ALL = "all"
+
+// PrimaryContigs 主要染色体 1-22, X, Y, M, MT，包括带chr前缀的名称
+func PrimaryContigs() []string {
+	names := make([]string, 0)
+	for i := 1; i <= 22; i++ {
+		names = append(names, fmt.Sprint(i))
+	}
+	names = append(names, "X", "Y", "M", "MT")
+	contigs := make([]string, 0)
+	for _, name := range names {
+		contigs = append(contigs, name, "chr"+name)
+	}
+	return contigs
+}
+
+// Contigs 注释的染色体白名单，为nil时注释所有染色体
+type Contigs map[string]bool
+
+// NewContigs 创建染色体白名单，names为空时使用PrimaryContigs，包含all时注释所有染色体
+func NewContigs(names []string) Contigs {
+	if len(names) == 0 {
+		names = PrimaryContigs()
+	}
+	contigs := make(Contigs)
+	for _, name := range names {
+		if name == CONTIGS_ALL {
+			return nil
+		}
+		contigs[name] = true
+	}
+	return contigs
+}
+
+// Allow 染色体是否在白名单中
+func (this Contigs) Allow(chrom string) bool {
+	return this == nil || this[chrom]
+}
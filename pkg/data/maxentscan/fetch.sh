@@ -0,0 +1,14 @@
+#!/bin/bash
+# 下载MaxEntScan发布包，并将打分模型复制到本目录以内嵌到程序中
+# usage: bash fetch.sh [url]
+set -e
+url=${1:-http://hollywood.mit.edu/burgelab/maxent/download/fordownload.tar.gz}
+outdir=$(cd $(dirname $0) && pwd)
+tmpdir=$(mktemp -d)
+trap "rm -rf $tmpdir" EXIT
+
+curl -fsSL $url | tar -xz -C $tmpdir
+srcdir=$(dirname $(find $tmpdir -name me2x5 | head -1))
+mkdir -p $outdir/splicemodels
+cp $srcdir/me2x5 $outdir/
+cp $srcdir/splicemodels/splice5sequences $srcdir/splicemodels/me2x3acc[1-9] $outdir/splicemodels/
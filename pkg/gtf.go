@@ -0,0 +1,356 @@
+package pkg
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	GeneModel_GTF  = "gtf"
+	GeneModel_GFF3 = "gff3"
+)
+
+// GeneModelFormat 根据文件名判断基因模型格式
+func GeneModelFormat(infile string) string {
+	name := strings.TrimSuffix(strings.ToLower(infile), ".gz")
+	if strings.HasSuffix(name, ".gtf") {
+		return GeneModel_GTF
+	}
+	return GeneModel_GFF3
+}
+
+// gffFeature GTF/GFF3中的一行记录
+type gffFeature struct {
+	Chrom  string
+	Type   string
+	Start  int
+	End    int
+	Strand string
+	Attrs  map[string][]string
+}
+
+// Attr 属性的第一个值
+func (this gffFeature) Attr(keys ...string) string {
+	for _, key := range keys {
+		if vals, ok := this.Attrs[key]; ok && len(vals) > 0 && vals[0] != "" {
+			return vals[0]
+		}
+	}
+	return ""
+}
+
+// parseGTFAttrs 解析GTF属性列，如 gene_id "ENSG00000012048"; tag "basic"; tag "CCDS";
+func parseGTFAttrs(text string) map[string][]string {
+	attrs := make(map[string][]string)
+	for _, field := range strings.Split(text, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(field, " ")
+		attrs[key] = append(attrs[key], strings.Trim(strings.TrimSpace(val), "\""))
+	}
+	return attrs
+}
+
+// parseGFF3Attrs 解析GFF3属性列，如 ID=transcript:ENST00000357654;tag=basic,CCDS
+func parseGFF3Attrs(text string) map[string][]string {
+	attrs := make(map[string][]string)
+	for _, field := range strings.Split(text, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(field, "=")
+		for _, v := range strings.Split(val, ",") {
+			if unescaped, err := url.PathUnescape(v); err == nil {
+				v = unescaped
+			}
+			attrs[key] = append(attrs[key], v)
+		}
+	}
+	return attrs
+}
+
+// newGffFeature 解析GTF/GFF3的一行
+func newGffFeature(line string, format string) (gffFeature, error) {
+	var feature gffFeature
+	row := strings.Split(line, "\t")
+	if len(row) != 9 {
+		return feature, fmt.Errorf("unknown %s format: %s", format, line)
+	}
+	var err error
+	feature.Chrom, feature.Type, feature.Strand = row[0], row[2], row[6]
+	if feature.Start, err = strconv.Atoi(row[3]); err != nil {
+		return feature, err
+	}
+	if feature.End, err = strconv.Atoi(row[4]); err != nil {
+		return feature, err
+	}
+	if format == GeneModel_GTF {
+		feature.Attrs = parseGTFAttrs(row[8])
+	} else {
+		feature.Attrs = parseGFF3Attrs(row[8])
+	}
+	return feature, nil
+}
+
+// gffTranscript 转录本及其exon、CDS信息
+type gffTranscript struct {
+	Feature gffFeature
+	Gene    gffFeature
+	Exons   [][2]int
+	Cds     [2]int
+}
+
+// addCds 合并CDS、start_codon、stop_codon区间
+func (this *gffTranscript) addCds(start, end int) {
+	if this.Cds[0] == 0 || start < this.Cds[0] {
+		this.Cds[0] = start
+	}
+	if end > this.Cds[1] {
+		this.Cds[1] = end
+	}
+}
+
+// chromFromAccession RefSeq NC_登录号对应的染色体，如 NC_000017.11 -> chr17
+func chromFromAccession(seqid string) string {
+	if strings.HasPrefix(seqid, "NC_") {
+		for _, accessions := range RefSeqChromAccessions {
+			for name, accession := range accessions {
+				if accession == seqid {
+					return "chr" + name
+				}
+			}
+		}
+	}
+	return seqid
+}
+
+// transcriptBiotypes GFF3特征类型对应的biotype，RefSeq中miRNA前体为primary_transcript，成熟miRNA为miRNA
+var transcriptBiotypes = map[string]string{
+	"mRNA":               Biotype_PROTEIN_CODING,
+	"lnc_RNA":            Biotype_LNCRNA,
+	"primary_transcript": Biotype_MIRNA,
+	"miRNA":              Biotype_MATURE_MIRNA,
+}
+
+// Transcript 转换为Transcript对象
+func (this gffTranscript) Transcript() Transcript {
+	var trans Transcript
+	feature, gene := this.Feature, this.Gene
+	trans.Name = feature.Attr("transcript_id", "Name")
+	if trans.Name == "" {
+		trans.Name = strings.TrimPrefix(strings.TrimPrefix(feature.Attr("ID"), "transcript:"), "rna-")
+	}
+	if version := feature.Attr("transcript_version", "version"); version != "" && !strings.Contains(trans.Name, ".") {
+		trans.Name = trans.Name + "." + version
+	}
+	trans.Chrom = chromFromAccession(feature.Chrom)
+	trans.Strand = feature.Strand
+	trans.Gene = feature.Attr("gene_name", "gene")
+	if trans.Gene == "" {
+		trans.Gene = gene.Attr("gene_name", "Name", "gene")
+	}
+	trans.SourceID = feature.Attr("gene_id")
+	if trans.SourceID == "" {
+		trans.SourceID = gene.Attr("gene_id")
+	}
+	for _, dbxref := range append(feature.Attrs["Dbxref"], gene.Attrs["Dbxref"]...) {
+		if trans.SourceID == "" && strings.HasPrefix(dbxref, "GeneID:") {
+			trans.SourceID = strings.TrimPrefix(dbxref, "GeneID:")
+		}
+	}
+	if trans.Gene == "" {
+		trans.Gene = trans.SourceID
+	}
+	trans.Biotype = feature.Attr("transcript_biotype", "transcript_type", "biotype")
+	if trans.Biotype == "" {
+		if biotype, ok := transcriptBiotypes[feature.Type]; ok {
+			trans.Biotype = biotype
+		} else if feature.Type != "transcript" {
+			trans.Biotype = feature.Type
+		} else {
+			trans.Biotype = gene.Attr("gene_biotype", "gene_type", "biotype")
+		}
+	}
+	for _, tag := range feature.Attrs["tag"] {
+		tag = strings.ReplaceAll(strings.TrimSpace(tag), " ", "_")
+		switch tag {
+		case "":
+			continue
+		case "cds_start_NF":
+			trans.CdsStartNF = true
+		case "cds_end_NF":
+			trans.CdsEndNF = true
+		}
+		trans.Tags = append(trans.Tags, tag)
+	}
+	sort.Slice(this.Exons, func(i, j int) bool { return this.Exons[i][0] < this.Exons[j][0] })
+	trans.ExonCount = len(this.Exons)
+	trans.ExonStarts = make([]int, trans.ExonCount)
+	trans.ExonEnds = make([]int, trans.ExonCount)
+	for i, exon := range this.Exons {
+		trans.ExonStarts[i], trans.ExonEnds[i] = exon[0], exon[1]
+	}
+	trans.TxStart, trans.TxEnd = trans.ExonStarts[0], trans.ExonEnds[trans.ExonCount-1]
+	if this.Cds[0] == 0 {
+		trans.CdsStart, trans.CdsEnd = trans.TxEnd+1, trans.TxEnd
+	} else {
+		trans.CdsStart, trans.CdsEnd = this.Cds[0], this.Cds[1]
+	}
+	return trans
+}
+
+// ReadGeneModel 读取GTF/GFF3文件并转换为Transcript对象，按染色体和起始位置排序
+func ReadGeneModel(infile string, format string) ([]Transcript, error) {
+	reader, err := NewIOReader(infile)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// GTF以transcript_id关联，GFF3以ID/Parent关联
+	features := make(map[string]gffFeature)
+	transcripts := make(map[string]*gffTranscript)
+	names := make([]string, 0)
+	getTranscript := func(name string) *gffTranscript {
+		if _, ok := transcripts[name]; !ok {
+			transcripts[name] = &gffTranscript{}
+			names = append(names, name)
+		}
+		return transcripts[name]
+	}
+	scanner := NewIOScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			if line == "##FASTA" {
+				break
+			}
+			continue
+		}
+		feature, err := newGffFeature(line, format)
+		if err != nil {
+			return nil, err
+		}
+		var parents []string
+		if format == GeneModel_GTF {
+			if name := feature.Attr("transcript_id"); name != "" {
+				parents = []string{name}
+				trans := getTranscript(name)
+				if trans.Feature.Attrs == nil || feature.Type == "transcript" {
+					trans.Feature = feature
+				}
+			}
+		} else {
+			if id := feature.Attr("ID"); id != "" {
+				features[id] = feature
+			}
+			parents = feature.Attrs["Parent"]
+		}
+		for _, parent := range parents {
+			switch feature.Type {
+			case "exon":
+				trans := getTranscript(parent)
+				trans.Exons = append(trans.Exons, [2]int{feature.Start, feature.End})
+			case "CDS", "start_codon", "stop_codon":
+				getTranscript(parent).addCds(feature.Start, feature.End)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]Transcript, 0, len(names))
+	for _, name := range names {
+		trans := transcripts[name]
+		if len(trans.Exons) == 0 {
+			continue
+		}
+		if format == GeneModel_GFF3 {
+			feature, ok := features[name]
+			if !ok {
+				return nil, fmt.Errorf("transcript not found in %s: %s", format, name)
+			}
+			trans.Feature = feature
+			trans.Gene = features[feature.Attr("Parent")]
+		}
+		result = append(result, trans.Transcript())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Chrom != result[j].Chrom {
+			return ChromLess(result[i].Chrom, result[j].Chrom)
+		}
+		return result[i].TxStart < result[j].TxStart
+	})
+	return result, nil
+}
+
+// cdsStats GenePred中的cdsStartStat、cdsEndStat，cds_start_NF/cds_end_NF为转录方向
+func (this Transcript) cdsStats() (string, string) {
+	if this.IsUnk() {
+		return "none", "none"
+	}
+	startNF, endNF := this.CdsStartNF, this.CdsEndNF
+	if this.Strand == "-" {
+		startNF, endNF = endNF, startNF
+	}
+	stats := [2]string{"cmpl", "cmpl"}
+	for i, nf := range []bool{startNF, endNF} {
+		if nf {
+			stats[i] = "incmpl"
+		}
+	}
+	return stats[0], stats[1]
+}
+
+// exonFrames 每个exon中CDS起始的读码框，无CDS时为-1
+func (this Transcript) exonFrames() []string {
+	frames := make([]string, this.ExonCount)
+	var cLen int
+	for k := 0; k < this.ExonCount; k++ {
+		i := k
+		if this.Strand == "-" {
+			i = this.ExonCount - 1 - k
+		}
+		start, end := Max(this.ExonStarts[i], this.CdsStart), Min(this.ExonEnds[i], this.CdsEnd)
+		if start > end {
+			frames[i] = "-1"
+			continue
+		}
+		frames[i] = strconv.Itoa(cLen % 3)
+		cLen += end - start + 1
+	}
+	return frames
+}
+
+// GenePredLine 转换为扩展GenePred格式的一行(含bin列)，并追加 gene_id、biotype、tags 三列
+func (this Transcript) GenePredLine() string {
+	exonStarts := make([]string, this.ExonCount)
+	exonEnds := make([]string, this.ExonCount)
+	for i := 0; i < this.ExonCount; i++ {
+		exonStarts[i] = strconv.Itoa(this.ExonStarts[i] - 1)
+		exonEnds[i] = strconv.Itoa(this.ExonEnds[i])
+	}
+	cdsStartStat, cdsEndStat := this.cdsStats()
+	sourceID, biotype, tags := this.SourceID, this.Biotype, strings.Join(this.Tags, ",")
+	for _, val := range []*string{&sourceID, &biotype, &tags} {
+		if *val == "" {
+			*val = "."
+		}
+	}
+	return strings.Join([]string{
+		strconv.Itoa(Reg2Bin(this.TxStart-1, this.TxEnd)),
+		this.Name, this.Chrom, this.Strand,
+		strconv.Itoa(this.TxStart - 1), strconv.Itoa(this.TxEnd),
+		strconv.Itoa(this.CdsStart - 1), strconv.Itoa(this.CdsEnd),
+		strconv.Itoa(this.ExonCount),
+		strings.Join(exonStarts, ",") + ",", strings.Join(exonEnds, ",") + ",",
+		"0", this.Gene, cdsStartStat, cdsEndStat,
+		strings.Join(this.exonFrames(), ",") + ",",
+		sourceID, biotype, tags,
+	}, "\t")
+}
@@ -0,0 +1,265 @@
+package pkg
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:generate bash data/maxentscan/fetch.sh
+//go:embed data/maxentscan
+var maxEntScanData embed.FS
+
+var (
+	mesBgd       = map[byte]float64{'A': 0.27, 'C': 0.23, 'G': 0.23, 'T': 0.27}
+	mesCons5     = [2]map[byte]float64{{'A': 0.004, 'C': 0.0032, 'G': 0.9896, 'T': 0.0032}, {'A': 0.0034, 'C': 0.0039, 'G': 0.0042, 'T': 0.9884}}
+	mesCons3     = [2]map[byte]float64{{'A': 0.9903, 'C': 0.0032, 'G': 0.0034, 'T': 0.0030}, {'A': 0.0027, 'C': 0.0037, 'G': 0.9905, 'T': 0.0030}}
+	mesSubseqs3  = [9][2]int{{0, 7}, {7, 7}, {14, 7}, {4, 7}, {11, 7}, {4, 3}, {7, 4}, {11, 3}, {14, 4}}
+	mesBaseIndex = map[byte]int{'A': 0, 'C': 1, 'G': 2, 'T': 3}
+)
+
+// MaxEntScan 最大熵模型剪接位点打分(Yeo & Burge, 2004)，5'ss为9-mer，3'ss为23-mer
+type MaxEntScan struct {
+	me2x5 map[string]float64
+	me2x3 [9][]float64
+}
+
+func readMaxEntScanFloats(fsys fs.FS, name string) ([]float64, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return []float64{}, err
+	}
+	scores := make([]float64, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		score, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return []float64{}, err
+		}
+		scores = append(scores, score)
+	}
+	return scores, nil
+}
+
+// NewMaxEntScan 从MaxEntScan目录结构(me2x5, splicemodels/)中读取模型
+func NewMaxEntScan(fsys fs.FS) (*MaxEntScan, error) {
+	var model MaxEntScan
+	scores, err := readMaxEntScanFloats(fsys, "me2x5")
+	if err != nil {
+		return &model, err
+	}
+	content, err := fs.ReadFile(fsys, "splicemodels/splice5sequences")
+	if err != nil {
+		return &model, err
+	}
+	model.me2x5 = make(map[string]float64)
+	i := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.ToUpper(strings.TrimSpace(line))
+		if line == "" {
+			continue
+		}
+		if i >= len(scores) {
+			return &model, errors.New("me2x5 and splice5sequences length mismatch")
+		}
+		model.me2x5[line] = scores[i]
+		i++
+	}
+	for j := 0; j < 9; j++ {
+		model.me2x3[j], err = readMaxEntScanFloats(fsys, fmt.Sprintf("splicemodels/me2x3acc%d", j+1))
+		if err != nil {
+			return &model, err
+		}
+	}
+	return &model, nil
+}
+
+// HasEmbeddedMaxEntScan 编译时是否在data/maxentscan中放入了MaxEntScan模型
+func HasEmbeddedMaxEntScan() bool {
+	_, err := fs.Stat(maxEntScanData, "data/maxentscan/me2x5")
+	return err == nil
+}
+
+// ReadMaxEntScan 读取MaxEntScan模型，indir为空时使用内嵌模型
+func ReadMaxEntScan(indir string) (*MaxEntScan, error) {
+	var fsys fs.FS
+	var err error
+	if indir == "" {
+		if !HasEmbeddedMaxEntScan() {
+			return nil, errors.New("MaxEntScan models are not embedded in this build, please set the MaxEntScan directory")
+		}
+		fsys, err = fs.Sub(maxEntScanData, "data/maxentscan")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fsys = os.DirFS(indir)
+	}
+	return NewMaxEntScan(fsys)
+}
+
+// mesHash 序列的4进制编码，如 CAGAAGT -> 4619
+func mesHash(seq string) (int, bool) {
+	var sum int
+	for i := 0; i < len(seq); i++ {
+		index, ok := mesBaseIndex[seq[i]]
+		if !ok {
+			return 0, false
+		}
+		sum = sum*4 + index
+	}
+	return sum, true
+}
+
+// Score5 5'剪接位点打分，seq为exon末3个碱基及intron前6个碱基
+func (this *MaxEntScan) Score5(seq string) (float64, bool) {
+	seq = strings.ToUpper(seq)
+	if len(seq) != 9 {
+		return 0, false
+	}
+	rest := seq[0:3] + seq[5:9]
+	score, ok := this.me2x5[rest]
+	if !ok {
+		return 0, false
+	}
+	cons := mesCons5[0][seq[3]] * mesCons5[1][seq[4]] / (mesBgd[seq[3]] * mesBgd[seq[4]])
+	if cons == 0 {
+		return 0, false
+	}
+	return math.Log2(cons * score), true
+}
+
+// Score3 3'剪接位点打分，seq为intron末20个碱基及exon前3个碱基
+func (this *MaxEntScan) Score3(seq string) (float64, bool) {
+	seq = strings.ToUpper(seq)
+	if len(seq) != 23 {
+		return 0, false
+	}
+	rest := seq[0:18] + seq[20:23]
+	var scores [9]float64
+	for i, subseq := range mesSubseqs3 {
+		hash, ok := mesHash(rest[subseq[0] : subseq[0]+subseq[1]])
+		if !ok || hash >= len(this.me2x3[i]) {
+			return 0, false
+		}
+		scores[i] = this.me2x3[i][hash]
+	}
+	cons := mesCons3[0][seq[18]] * mesCons3[1][seq[19]] / (mesBgd[seq[18]] * mesBgd[seq[19]])
+	score := scores[0] * scores[1] * scores[2] * scores[3] * scores[4] / (scores[5] * scores[6] * scores[7] * scores[8])
+	if cons == 0 || score == 0 || math.IsInf(score, 0) || math.IsNaN(score) {
+		return 0, false
+	}
+	return math.Log2(cons * score), true
+}
+
+// MaxEntScore 转录本剪接位点在变异前后的MaxEntScan打分
+type MaxEntScore struct {
+	Site string  `json:"site"` // donor or acceptor
+	Ref  float64 `json:"ref"`
+	Alt  float64 `json:"alt"`
+}
+
+// Diff 变异后与变异前的打分差值
+func (this MaxEntScore) Diff() float64 {
+	return this.Alt - this.Ref
+}
+
+// mutate 在转录本序列中引入变异，返回变异后序列及基因组位置到变异后序列位置的映射
+func (this Transcript) mutate(seq string, variant AnnoVariant) (string, func(int) int) {
+	start, refLen, alt := variant.Start, variant.End-variant.Start+1, variant.Alt
+	if variant.Ref == "-" {
+		start, refLen = variant.Start+1, 0
+	}
+	if alt == "-" {
+		alt = ""
+	}
+	i := start - this.TxStart
+	nseq := seq[0:i] + alt + seq[i+refLen:]
+	posMap := func(pos int) int {
+		if pos < start {
+			return pos
+		}
+		if pos >= start+refLen {
+			return pos + len(alt) - refLen
+		}
+		return start
+	}
+	return nseq, posMap
+}
+
+// MaxEntScores 距离变异最近的供体及受体位点在变异前后的MaxEntScan打分，变异不影响位点序列时不打分
+func (this Transcript) MaxEntScores(variant AnnoVariant, model *MaxEntScan) []MaxEntScore {
+	scores := make([]MaxEntScore, 0)
+	seq := this.DNA()
+	if model == nil || len(seq) != this.TxEnd-this.TxStart+1 || variant.Start < this.TxStart || variant.End > this.TxEnd {
+		return scores
+	}
+	nseq, posMap := this.mutate(seq, variant)
+	// window 提取位点序列，left/right为位点两侧(基因组正链方向)的碱基数
+	window := func(seq string, pos, left, right int) string {
+		start, end := pos-left-this.TxStart, pos+right-this.TxStart+1
+		if start < 0 || end > len(seq) {
+			return ""
+		}
+		if this.Strand == "-" {
+			return RevComp(seq[start:end])
+		}
+		return seq[start:end]
+	}
+	var donor, acceptor int
+	donorDist, acceptorDist := -1, -1
+	for _, region := range this.Regions {
+		if region.Type != RType_INTRON {
+			continue
+		}
+		donorPos, acceptorPos := region.Start, region.End
+		if this.Strand == "-" {
+			donorPos, acceptorPos = region.End, region.Start
+		}
+		if dist := Abs(variant.Start - donorPos); donorDist < 0 || dist < donorDist {
+			donor, donorDist = donorPos, dist
+		}
+		if dist := Abs(variant.Start - acceptorPos); acceptorDist < 0 || dist < acceptorDist {
+			acceptor, acceptorDist = acceptorPos, dist
+		}
+	}
+	if donorDist >= 0 {
+		// 供体位点: exon末3个碱基 + intron前6个碱基
+		left, right := 3, 5
+		if this.Strand == "-" {
+			left, right = 5, 3
+		}
+		refSeq, altSeq := window(seq, donor, left, right), window(nseq, posMap(donor), left, right)
+		if refSeq != altSeq {
+			ref, ok1 := model.Score5(refSeq)
+			alt, ok2 := model.Score5(altSeq)
+			if ok1 && ok2 {
+				scores = append(scores, MaxEntScore{Site: "donor", Ref: ref, Alt: alt})
+			}
+		}
+	}
+	if acceptorDist >= 0 {
+		// 受体位点: intron末20个碱基 + exon前3个碱基
+		left, right := 19, 3
+		if this.Strand == "-" {
+			left, right = 3, 19
+		}
+		refSeq, altSeq := window(seq, acceptor, left, right), window(nseq, posMap(acceptor), left, right)
+		if refSeq != altSeq {
+			ref, ok1 := model.Score3(refSeq)
+			alt, ok2 := model.Score3(altSeq)
+			if ok1 && ok2 {
+				scores = append(scores, MaxEntScore{Site: "acceptor", Ref: ref, Alt: alt})
+			}
+		}
+	}
+	return scores
+}
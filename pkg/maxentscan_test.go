@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testMaxEntScanFS 构造MaxEntScan目录结构的模型，me2x3acc1-5均为2，me2x3acc6-9均为1
+func testMaxEntScanFS() fstest.MapFS {
+	fsys := fstest.MapFS{
+		"me2x5":                         {Data: []byte("4.0\n0.5\n")},
+		"splicemodels/splice5sequences": {Data: []byte("CAGAAGT\ncagaagc\n")},
+	}
+	for i, subseq := range mesSubseqs3 {
+		val := "2.0\n"
+		if i >= 5 {
+			val = "1.0\n"
+		}
+		fsys[fmt.Sprintf("splicemodels/me2x3acc%d", i+1)] = &fstest.MapFile{Data: []byte(strings.Repeat(val, 1<<(2*subseq[1])))}
+	}
+	return fsys
+}
+
+func TestMaxEntScanSynthetic(t *testing.T) {
+	model, err := NewMaxEntScan(testMaxEntScanFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cons5 := 0.9896 * 0.9884 / (0.23 * 0.27)
+	cons3 := 0.9903 * 0.9905 / (0.27 * 0.23)
+	tests := []struct {
+		name  string
+		score func(string) (float64, bool)
+		seq   string
+		want  float64
+		ok    bool
+	}{
+		{"donor", model.Score5, "cagGTAAGT", math.Log2(cons5 * 4), true},
+		{"donor lower case model", model.Score5, "CAGGTAAGC", math.Log2(cons5 * 0.5), true},
+		{"donor GC", model.Score5, "CAGGCAAGT", math.Log2(0.9896 * 0.0039 / (0.23 * 0.23) * 4), true},
+		{"donor not in model", model.Score5, "CAGGTAAAA", 0, false},
+		{"donor length", model.Score5, "CAGGTAAG", 0, false},
+		{"acceptor", model.Score3, "ttccaaacgaacttttgtAGgga", math.Log2(cons3 * 32), true},
+		{"acceptor N", model.Score3, "ttccaaacgaacttNtgtAGgga", 0, false},
+		{"acceptor length", model.Score3, "tccaaacgaacttttgtAGgga", 0, false},
+	}
+	for _, test := range tests {
+		score, ok := test.score(test.seq)
+		if ok != test.ok || math.Abs(score-test.want) > 1e-9 {
+			t.Errorf("%s %s: got %v %v, want %v %v", test.name, test.seq, score, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestMaxEntScanLengthMismatch(t *testing.T) {
+	fsys := testMaxEntScanFS()
+	fsys["me2x5"] = &fstest.MapFile{Data: []byte("4.0\n")}
+	if _, err := NewMaxEntScan(fsys); err == nil {
+		t.Error("expect error when me2x5 is shorter than splice5sequences")
+	}
+}
+
+// TestMaxEntScanReference 与MaxEntScan score5.pl及score3.pl的示例打分比较，
+// 模型来自MAXENTSCAN_DIR或内嵌模型，均不存在时跳过
+func TestMaxEntScanReference(t *testing.T) {
+	indir := os.Getenv("MAXENTSCAN_DIR")
+	if indir == "" && !HasEmbeddedMaxEntScan() {
+		t.Skip("MaxEntScan models not found, set MAXENTSCAN_DIR to the MaxEntScan directory")
+	}
+	model, err := ReadMaxEntScan(indir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score, ok := model.Score5("cagGTAAGT"); !ok || math.Abs(score-10.86) > 0.01 {
+		t.Errorf("score5 cagGTAAGT: got %.2f %v, want 10.86", score, ok)
+	}
+	if score, ok := model.Score3("ttccaaacgaacttttgtAGgga"); !ok || math.Abs(score-2.89) > 0.01 {
+		t.Errorf("score3 ttccaaacgaacttttgtAGgga: got %.2f %v, want 2.89", score, ok)
+	}
+}
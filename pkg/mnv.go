@@ -0,0 +1,194 @@
+package pkg
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/brentp/faidx"
+	"github.com/brentp/vcfgo"
+)
+
+// MNV_DISTANCE 合并注释的SNP的最大间距，即同一exon内一个密码子的跨度；跨越exon边界的密码子不合并
+const MNV_DISTANCE = 2
+
+// carries 样本基因型中携带该ALT的拷贝，返回每条单倍型是否携带；拆分后的记录中ALT记为1
+func (this *SNV) carries(sample int) []bool {
+	if sample >= len(this.Samples) || this.Samples[sample] == nil {
+		return []bool{}
+	}
+	gts := this.Samples[sample].GT
+	haps := make([]bool, len(gts))
+	for i, gt := range gts {
+		haps[i] = gt == 1
+	}
+	return haps
+}
+
+// InCis 判断两个变异在指定样本中是否位于同一单倍型：
+// 任一变异为纯合时视为同一单倍型，否则要求均为相位基因型、PS相同且ALT位于同一条单倍型
+func InCis(snv1, snv2 *SNV, sample int) bool {
+	haps1, haps2 := snv1.carries(sample), snv2.carries(sample)
+	hom := func(haps []bool) bool {
+		for _, hap := range haps {
+			if !hap {
+				return false
+			}
+		}
+		return len(haps) > 0
+	}
+	carry := func(haps []bool) bool {
+		for _, hap := range haps {
+			if hap {
+				return true
+			}
+		}
+		return false
+	}
+	if !carry(haps1) || !carry(haps2) {
+		return false
+	}
+	if hom(haps1) || hom(haps2) {
+		return true
+	}
+	geno1, geno2 := snv1.Samples[sample], snv2.Samples[sample]
+	if !geno1.Phased || !geno2.Phased || geno1.Fields["PS"] != geno2.Fields["PS"] {
+		return false
+	}
+	for i := 0; i < len(haps1) && i < len(haps2); i++ {
+		if haps1[i] && haps2[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupMNVs 按样本将位于同一单倍型、同一转录本同一密码子且间距不超过MNV_DISTANCE的SNP分组，只返回包含多个SNP的分组；
+// codons为每个SNP(以PK为键)在各重叠编码转录本上的密码子，FORMAT=Chrom:Transcript:密码子序号
+func GroupMNVs(snvs []*SNV, codons map[string][]string) [][]*SNV {
+	snps := make([]*SNV, 0)
+	nsample := 0
+	for _, snv := range snvs {
+		if snv.Type() == VType_SNP {
+			snps = append(snps, snv)
+			nsample = Max(nsample, len(snv.Samples))
+		}
+	}
+	sort.SliceStable(snps, func(i, j int) bool {
+		if snps[i].Chrom() == snps[j].Chrom() {
+			return snps[i].Pos < snps[j].Pos
+		}
+		return snps[i].Chrom() < snps[j].Chrom()
+	})
+	groups := make([][]*SNV, 0)
+	seen := make(map[string]bool)
+	addGroup := func(group []*SNV) {
+		if len(group) < 2 {
+			return
+		}
+		pks := make([]string, len(group))
+		for i, snv := range group {
+			pks[i] = snv.PK()
+		}
+		key := strings.Join(pks, ",")
+		if !seen[key] {
+			seen[key] = true
+			groups = append(groups, group)
+		}
+	}
+	for sample := 0; sample < nsample; sample++ {
+		keys := make([]string, 0)
+		codonSnps := make(map[string][]*SNV)
+		for _, snp := range snps {
+			if len(snp.carries(sample)) == 0 {
+				continue
+			}
+			for _, codon := range codons[snp.PK()] {
+				if _, ok := codonSnps[codon]; !ok {
+					keys = append(keys, codon)
+				}
+				codonSnps[codon] = append(codonSnps[codon], snp)
+			}
+		}
+		for _, codon := range keys {
+			for _, group := range cisGroups(codonSnps[codon], sample) {
+				addGroup(group)
+			}
+		}
+	}
+	return groups
+}
+
+// cisGroups 同一密码子中两两位于同一单倍型的SNP的最大组合，snps已按位置排序
+func cisGroups(snps []*SNV, sample int) [][]*SNV {
+	cis := func(snv1, snv2 *SNV) bool {
+		return snv1.Pos != snv2.Pos && Abs(int(snv1.Pos)-int(snv2.Pos)) <= MNV_DISTANCE && InCis(snv1, snv2, sample)
+	}
+	masks := make([]int, 0)
+	for mask := 1; mask < 1<<len(snps); mask++ {
+		if bits.OnesCount(uint(mask)) >= 2 {
+			masks = append(masks, mask)
+		}
+	}
+	// 成员多的组合优先，已选组合的子集不再输出
+	sort.SliceStable(masks, func(i, j int) bool {
+		return bits.OnesCount(uint(masks[i])) > bits.OnesCount(uint(masks[j]))
+	})
+	groups := make([][]*SNV, 0)
+	selected := make([]int, 0)
+	for _, mask := range masks {
+		subset := false
+		for _, other := range selected {
+			subset = subset || mask&other == mask
+		}
+		if subset {
+			continue
+		}
+		group := make([]*SNV, 0)
+		ok := true
+		for i, snp := range snps {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			for _, other := range group {
+				ok = ok && cis(other, snp)
+			}
+			group = append(group, snp)
+		}
+		if ok {
+			selected = append(selected, mask)
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// NewMNV 将同一单倍型上的多个SNP合并为一个替换变异，中间碱基取自参考基因组
+func NewMNV(group []*SNV, genome *faidx.Faidx) (*SNV, error) {
+	first, last := group[0], group[len(group)-1]
+	ref, err := genome.Get(first.Chrom(), int(first.Pos)-1, int(last.Pos))
+	if err != nil {
+		return &SNV{}, err
+	}
+	ref = strings.ToUpper(ref)
+	alt := []byte(ref)
+	for _, snv := range group {
+		alt[snv.Pos-first.Pos] = strings.ToUpper(snv.Alt()[0])[0]
+	}
+	variant := vcfgo.Variant{
+		Chromosome: first.Chrom(),
+		Pos:        first.Pos,
+		Id_:        ".",
+		Reference:  ref,
+		Alternate:  []string{string(alt)},
+		Info_:      vcfgo.NewInfoByte([]byte{}, first.Header),
+		Header:     first.Header,
+	}
+	return &SNV{Variant: variant}, nil
+}
+
+// MNVName MNV名称，FORMAT=Chrom:Pos:Ref/Alt
+func (this *SNV) MNVName() string {
+	return fmt.Sprintf("%s:%d:%s/%s", this.Chrom(), this.Pos, this.Ref(), this.Alt()[0])
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brentp/vcfgo"
+)
+
+func newTestMNVSnp(pos uint64, gt string) *SNV {
+	phased := strings.Contains(gt, "|")
+	geno := &vcfgo.SampleGenotype{Phased: phased, Fields: map[string]string{"PS": "1"}}
+	for _, allele := range strings.FieldsFunc(gt, func(r rune) bool { return r == '|' || r == '/' }) {
+		geno.GT = append(geno.GT, int(allele[0]-'0'))
+	}
+	return &SNV{Variant: vcfgo.Variant{Chromosome: "chr1", Pos: pos, Reference: "C", Alternate: []string{"A"}, Samples: []*vcfgo.SampleGenotype{geno}}}
+}
+
+func TestGroupMNVs(t *testing.T) {
+	tests := []struct {
+		name   string
+		snps   []*SNV
+		codons []string // 每个SNP所在的密码子，以|分隔多个转录本
+		want   []string
+	}{
+		{
+			"same codon in cis",
+			[]*SNV{newTestMNVSnp(100, "0|1"), newTestMNVSnp(101, "0|1")},
+			[]string{"T1:34", "T1:34"},
+			[]string{"100,101"},
+		},
+		{
+			"same codon in trans",
+			[]*SNV{newTestMNVSnp(100, "0|1"), newTestMNVSnp(101, "1|0")},
+			[]string{"T1:34", "T1:34"},
+			[]string{},
+		},
+		{
+			"adjacent codons are not chained",
+			[]*SNV{newTestMNVSnp(100, "0|1"), newTestMNVSnp(101, "0|1"), newTestMNVSnp(102, "0|1")},
+			[]string{"T1:33", "T1:34", "T1:34"},
+			[]string{"101,102"},
+		},
+		{
+			"codon of each transcript",
+			[]*SNV{newTestMNVSnp(100, "0|1"), newTestMNVSnp(101, "0|1"), newTestMNVSnp(102, "0|1")},
+			[]string{"T1:34|T2:17", "T1:34|T2:17", "T1:34|T2:18"},
+			[]string{"100,101,102", "100,101"},
+		},
+		{
+			"homozygous neighbour",
+			[]*SNV{newTestMNVSnp(100, "0/1"), newTestMNVSnp(101, "1/1"), newTestMNVSnp(102, "0/1")},
+			[]string{"T1:34", "T1:34", "T1:34"},
+			[]string{"100,101", "101,102"},
+		},
+		{
+			"codon split by intron",
+			[]*SNV{newTestMNVSnp(100, "0|1"), newTestMNVSnp(200, "0|1")},
+			[]string{"T1:34", "T1:34"},
+			[]string{},
+		},
+		{
+			"not in CDS",
+			[]*SNV{newTestMNVSnp(100, "0|1"), newTestMNVSnp(101, "0|1")},
+			[]string{"", ""},
+			[]string{},
+		},
+	}
+	for _, test := range tests {
+		codons := make(map[string][]string)
+		for i, snp := range test.snps {
+			if test.codons[i] != "" {
+				codons[snp.PK()] = strings.Split(test.codons[i], "|")
+			}
+		}
+		groups := make([]string, 0)
+		for _, group := range GroupMNVs(test.snps, codons) {
+			pos := make([]string, len(group))
+			for i, snv := range group {
+				pos[i] = strings.Split(snv.PK(), ":")[1]
+			}
+			groups = append(groups, strings.Join(pos, ","))
+		}
+		if strings.Join(groups, ";") != strings.Join(test.want, ";") {
+			t.Errorf("%s: got %v, want %v", test.name, groups, test.want)
+		}
+	}
+}
+
+func TestTranscriptCodon(t *testing.T) {
+	// CDS为1101-1200, 1401-1600, 1801-1900
+	tests := []struct {
+		strand string
+		pos    int
+		codon  int
+		ok     bool
+	}{
+		{"+", 1101, 1, true},
+		{"+", 1103, 1, true},
+		{"+", 1104, 2, true},
+		{"+", 1200, 34, true},
+		{"+", 1401, 34, true},
+		{"+", 1402, 34, true},
+		{"+", 1403, 35, true},
+		{"+", 1050, 0, false},
+		{"+", 1300, 0, false},
+		{"-", 1900, 1, true},
+		{"-", 1898, 1, true},
+		{"-", 1897, 2, true},
+		{"-", 1101, 134, true},
+	}
+	for _, test := range tests {
+		line := "0\tNM_TEST\tchr1\t" + test.strand + "\t1000\t2000\t1100\t1900\t3\t1000,1400,1800,\t1200,1600,2000,\t0\tGENE\tcmpl\tcmpl\t0,1,2,"
+		trans, err := NewTranscript(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.SetRegions()
+		codon, ok := trans.Codon(test.pos)
+		if codon != test.codon || ok != test.ok {
+			t.Errorf("%s %d: got %d %v, want %d %v", test.strand, test.pos, codon, ok, test.codon, test.ok)
+		}
+	}
+}
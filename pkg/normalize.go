@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brentp/faidx"
+)
+
+// CheckRef 检查REF是否与参考基因组一致，参考基因组中的N视为一致，染色体不在参考基因组中时视为不一致
+func (this *SNV) CheckRef(genome *faidx.Faidx) (bool, error) {
+	ref := strings.ToUpper(this.Ref())
+	chrom := this.Chrom()
+	index, ok := genome.Index[chrom]
+	if !ok {
+		return false, nil
+	}
+	start, end := int(this.Pos)-1, int(this.Pos)-1+len(ref)
+	if start < 0 || end > index.Length {
+		return false, nil
+	}
+	seq, err := genome.Get(chrom, start, end)
+	if err != nil {
+		return false, err
+	}
+	seq = strings.ToUpper(seq)
+	for i := 0; i < len(ref); i++ {
+		if ref[i] != seq[i] && ref[i] != 'N' && seq[i] != 'N' {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Normalize 参照 bcftools norm 左对齐并精简变异，返回是否发生改变；
+// REF与参考基因组不一致时不做处理，由CheckRef判断
+func (this *SNV) Normalize(genome *faidx.Faidx) (bool, error) {
+	pos, ref, alt, err := NormalizeAlleles(genome, this.Chrom(), int(this.Pos), this.Ref(), this.Alt()[0])
+	if err != nil {
+		return false, err
+	}
+	if pos == int(this.Pos) && ref == strings.ToUpper(this.Ref()) && alt == strings.ToUpper(this.Alt()[0]) {
+		return false, nil
+	}
+	origin := fmt.Sprintf("%s:%d:%s/%s", this.Chrom(), this.Pos, this.Ref(), this.Alt()[0])
+	this.Pos, this.Reference, this.Alternate = uint64(pos), ref, []string{alt}
+	return true, this.Info().Set("OLD_VARIANT", origin)
+}
+
+// NormalizeAlleles 左对齐并精简一对等位，返回新的位置及REF、ALT，符号等位(<DEL>等)不做处理
+func NormalizeAlleles(genome *faidx.Faidx, chrom string, pos int, ref, alt string) (int, string, string, error) {
+	ref, alt = strings.ToUpper(ref), strings.ToUpper(alt)
+	if ref == alt || strings.HasPrefix(alt, "<") || strings.ContainsAny(alt, "[]*.") {
+		return pos, ref, alt, nil
+	}
+	for {
+		changed := false
+		// 去除末尾相同碱基
+		if len(ref) > 0 && len(alt) > 0 && ref[len(ref)-1] == alt[len(alt)-1] {
+			ref, alt = ref[:len(ref)-1], alt[:len(alt)-1]
+			changed = true
+		}
+		// 任一等位为空时向左补齐一个碱基
+		if len(ref) == 0 || len(alt) == 0 {
+			if pos <= 1 {
+				break
+			}
+			base, err := genome.Get(chrom, pos-2, pos-1)
+			if err != nil {
+				return pos, ref, alt, err
+			}
+			base = strings.ToUpper(base)
+			ref, alt = base+ref, base+alt
+			pos--
+			changed = true
+		}
+		if !changed {
+			break
+		}
+	}
+	// 去除开头相同碱基
+	for len(ref) > 1 && len(alt) > 1 && ref[0] == alt[0] {
+		ref, alt = ref[1:], alt[1:]
+		pos++
+	}
+	return pos, ref, alt, nil
+}
+
+// TrimAlleles 不依赖参考基因组精简等位，依次去除REF、ALT末尾及开头相同的碱基，至少保留一个碱基，
+// 用于比较不同表示方式的同一变异，如拆分后的多等位位点ATT>AT与AT>A
+func TrimAlleles(pos int, ref, alt string) (int, string, string) {
+	ref, alt = strings.ToUpper(ref), strings.ToUpper(alt)
+	for len(ref) > 1 && len(alt) > 1 && ref[len(ref)-1] == alt[len(alt)-1] {
+		ref, alt = ref[:len(ref)-1], alt[:len(alt)-1]
+	}
+	for len(ref) > 1 && len(alt) > 1 && ref[0] == alt[0] {
+		ref, alt = ref[1:], alt[1:]
+		pos++
+	}
+	return pos, ref, alt
+}
@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brentp/faidx"
+)
+
+const (
+	Build_GRCh37 = "GRCh37"
+	Build_GRCh38 = "GRCh38"
+)
+
+// RefSeqChromAccessions 染色体对应的RefSeq NC_登录号
+var RefSeqChromAccessions = map[string]map[string]string{
+	"GRCh37": {
+		"1": "NC_000001.10", "2": "NC_000002.11", "3": "NC_000003.11", "4": "NC_000004.11", "5": "NC_000005.9",
+		"6": "NC_000006.11", "7": "NC_000007.13", "8": "NC_000008.10", "9": "NC_000009.11", "10": "NC_000010.10",
+		"11": "NC_000011.9", "12": "NC_000012.11", "13": "NC_000013.10", "14": "NC_000014.8", "15": "NC_000015.9",
+		"16": "NC_000016.9", "17": "NC_000017.10", "18": "NC_000018.9", "19": "NC_000019.9", "20": "NC_000020.10",
+		"21": "NC_000021.8", "22": "NC_000022.10", "X": "NC_000023.10", "Y": "NC_000024.9", "M": "NC_012920.1",
+	},
+	"GRCh38": {
+		"1": "NC_000001.11", "2": "NC_000002.12", "3": "NC_000003.12", "4": "NC_000004.12", "5": "NC_000005.10",
+		"6": "NC_000006.12", "7": "NC_000007.14", "8": "NC_000008.11", "9": "NC_000009.12", "10": "NC_000010.11",
+		"11": "NC_000011.10", "12": "NC_000012.12", "13": "NC_000013.11", "14": "NC_000014.9", "15": "NC_000015.10",
+		"16": "NC_000016.10", "17": "NC_000017.11", "18": "NC_000018.10", "19": "NC_000019.10", "20": "NC_000020.11",
+		"21": "NC_000021.9", "22": "NC_000022.11", "X": "NC_000023.11", "Y": "NC_000024.10", "M": "NC_012920.1",
+	},
+}
+
+// RefSeqAccession 染色体对应的RefSeq登录号，如 chr17 -> NC_000017.11
+func RefSeqAccession(chrom string, build string) (string, bool) {
+	name := strings.TrimPrefix(chrom, "chr")
+	if name == "MT" {
+		name = "M"
+	}
+	accession, ok := RefSeqChromAccessions[build][name]
+	return accession, ok
+}
+
+// TransAccession 带版本号的转录本登录号及其蛋白登录号
+type TransAccession struct {
+	Transcript string
+	Protein    string
+}
+
+// TransAccessions 转录本对应的登录号，以带版本号及不带版本号的转录本名为键
+type TransAccessions map[string]TransAccession
+
+// ReadTransAccessions 读取转录本与蛋白登录号对应关系, FORMAT=Transcript\tProtein，非编码转录本的Protein为-
+func ReadTransAccessions(infile string) (TransAccessions, error) {
+	accessions := make(TransAccessions)
+	reader, err := NewIOReader(infile)
+	if err != nil {
+		return accessions, err
+	}
+	defer reader.Close()
+	scanner := NewCSVScanner(reader)
+	for scanner.Scan() {
+		row := scanner.Row()
+		accession := TransAccession{Transcript: row["Transcript"], Protein: row["Protein"]}
+		if accession.Protein == "-" {
+			accession.Protein = ""
+		}
+		accessions[row["Transcript"]] = accession
+		accessions[strings.Split(row["Transcript"], ".")[0]] = accession
+	}
+	return accessions, nil
+}
+
+// Transcript 转录本带版本号的登录号，GenePred中的转录本名已带版本号或无对应关系时使用转录本名
+func (this TransAccessions) Transcript(trans Transcript) string {
+	if strings.Contains(trans.Name, ".") {
+		return trans.Name
+	}
+	if accession, ok := this[trans.Name]; ok {
+		return accession.Transcript
+	}
+	return trans.Name
+}
+
+// Protein 转录本对应的蛋白登录号
+func (this TransAccessions) Protein(trans Transcript) string {
+	if accession, ok := this[trans.Name]; ok {
+		return accession.Protein
+	}
+	return this[strings.Split(trans.Name, ".")[0]].Protein
+}
+
+// shiftGenomic 按正链3'原则在基因组上移动Indel
+func (this AnnoVariant) shiftGenomic(genome *faidx.Faidx) AnnoVariant {
+	if this.Ref != "-" && this.Alt != "-" {
+		return this
+	}
+	size, end := 100, this.End
+	var seq string
+	var offset int
+	for {
+		// seq 为 End 之后的序列，offset 为已移动的碱基数
+		if offset >= len(seq) {
+			record, ok := genome.Index[this.Chrom]
+			if !ok || end+len(seq) >= record.Length {
+				break
+			}
+			next, err := genome.Get(this.Chrom, end+len(seq), Min(end+len(seq)+size, record.Length))
+			if err != nil || len(next) == 0 {
+				break
+			}
+			seq += strings.ToUpper(next)
+		}
+		if this.Ref == "-" {
+			if seq[offset] != this.Alt[0] {
+				break
+			}
+			this.Alt = this.Alt[1:] + this.Alt[0:1]
+		} else {
+			if seq[offset] != this.Ref[0] {
+				break
+			}
+			this.Ref = this.Ref[1:] + this.Ref[0:1]
+		}
+		offset++
+		this.Start++
+		this.End++
+	}
+	return this
+}
+
+// HGVSg 变异的基因组HGVS表达式，如 NC_000017.11:g.7675088C>T
+func (this AnnoVariant) HGVSg(genome *faidx.Faidx, build string) (string, error) {
+	accession, ok := RefSeqAccession(this.Chrom, build)
+	if !ok {
+		accession = this.Chrom
+	}
+	prefix := "g"
+	if accession == "NC_012920.1" {
+		prefix = "m"
+	}
+	variant := this.shiftGenomic(genome)
+	var change string
+	if variant.Ref == "-" {
+		length := len(variant.Alt)
+		var before string
+		if record, ok := genome.Index[variant.Chrom]; ok && variant.Start <= record.Length {
+			before, _ = genome.Get(variant.Chrom, Max(variant.Start-length, 0), variant.Start)
+		}
+		if strings.ToUpper(before) == variant.Alt {
+			if length == 1 {
+				change = fmt.Sprintf("%ddup", variant.Start)
+			} else {
+				change = fmt.Sprintf("%d_%ddup", variant.Start-length+1, variant.Start)
+			}
+		} else {
+			change = fmt.Sprintf("%d_%dins%s", variant.Start, variant.Start+1, variant.Alt)
+		}
+	} else if variant.Alt == "-" {
+		if variant.Start == variant.End {
+			change = fmt.Sprintf("%ddel", variant.Start)
+		} else {
+			change = fmt.Sprintf("%d_%ddel", variant.Start, variant.End)
+		}
+	} else if len(variant.Ref) == 1 && len(variant.Alt) == 1 {
+		change = fmt.Sprintf("%d%s>%s", variant.Start, variant.Ref, variant.Alt)
+	} else {
+		if variant.Start == variant.End {
+			change = fmt.Sprintf("%ddelins%s", variant.Start, variant.Alt)
+		} else {
+			change = fmt.Sprintf("%d_%ddelins%s", variant.Start, variant.End, variant.Alt)
+		}
+	}
+	return fmt.Sprintf("%s:%s.%s", accession, prefix, change), nil
+}
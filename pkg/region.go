@@ -12,8 +12,6 @@ const (
 	RType_UTR    = "UTR"
 )
 
-var IS_EXON_REGION = false
-
 // Region Transcript的区域元件，如Intron，CDS等
 type Region struct {
 	Chrom string `json:"chrom"`
@@ -26,9 +24,9 @@ type Region struct {
 	Sequence string `json:"sequence"`
 }
 
-// Name Region的名称，根据IS_EXON_REGION，返回Exon编号或元件编号
-func (this *Region) Name() string {
-	if IS_EXON_REGION && this.Type == RType_CDS {
+// Name Region的名称，exon为true时CDS返回Exon编号，否则返回元件编号
+func (this *Region) Name(exon bool) string {
+	if exon && this.Type == RType_CDS {
 		return this.Exon
 	}
 	if this.Order == 0 {
@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	RepSource_MANE_SELECT   = "MANE_Select"
+	RepSource_MANE_PLUS_CLN = "MANE_Plus_Clinical"
+)
+
+// RepTranscripts 代表性转录本及其来源，来自 tools rt 的输出
+type RepTranscripts map[string]string
+
+// repTransKey 代表性转录本主键，转录本不含版本号
+func repTransKey(chrom, gene, name string) string {
+	return fmt.Sprintf("%s\t%s\t%s", chrom, gene, strings.Split(name, ".")[0])
+}
+
+// ReadRepTranscripts 读取代表性转录本文件，FORMAT=Chrom\tGene\tTranscript\tSource
+func ReadRepTranscripts(infile string) (RepTranscripts, error) {
+	sources := make(RepTranscripts)
+	reader, err := NewIOReader(infile)
+	if err != nil {
+		return sources, err
+	}
+	defer reader.Close()
+	scanner := NewIOScanner(reader)
+	for scanner.Scan() {
+		row := strings.Split(scanner.Text(), "\t")
+		if len(row) < 4 {
+			continue
+		}
+		sources[repTransKey(row[0], row[1], row[2])] = row[3]
+	}
+	return sources, nil
+}
+
+// RepSource 代表性转录本来源，非代表性转录本为空；未在代表性转录本文件中时使用基因模型自带的MANE标签
+func (this RepTranscripts) Source(trans Transcript) string {
+	if source, ok := this[repTransKey(trans.Chrom, trans.Gene, trans.Name)]; ok {
+		return source
+	}
+	for _, source := range []string{RepSource_MANE_SELECT, RepSource_MANE_PLUS_CLN} {
+		if trans.HasTag(source) {
+			return source
+		}
+	}
+	return ""
+}
+
+// IsMANE 是否为MANE转录本
+func IsMANE(source string) bool {
+	return source == RepSource_MANE_SELECT || source == RepSource_MANE_PLUS_CLN
+}
+
+// GenePredHasMANE GenePred文件中是否有带MANE标签的转录本，仅 pre gtf 输出的19列格式带有标签
+func GenePredHasMANE(infile string) (bool, error) {
+	reader, err := NewIOReader(infile)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+	scanner := NewIOScanner(reader)
+	for scanner.Scan() {
+		row := strings.Split(scanner.Text(), "\t")
+		if len(row) != 19 {
+			continue
+		}
+		for _, tag := range strings.Split(row[18], ",") {
+			if IsMANE(tag) {
+				return true, nil
+			}
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,97 @@
+package pkg
+
+const (
+	SType_DONOR          = "splice_donor"
+	SType_ACCEPTOR       = "splice_acceptor"
+	SType_DONOR_5TH_BASE = "splice_donor_5th_base"
+	SType_REGION         = "splice_region"
+	SType_POLYPYRIMIDINE = "splice_polypyrimidine_tract"
+)
+
+// SpliceLens 剪接区域长度
+type SpliceLens struct {
+	Site           int // intron内剪接供体/受体位点长度
+	RegionExon     int // exon内剪接区域长度
+	RegionIntron   int // intron内剪接区域长度
+	Polypyrimidine int // 受体位点上游多聚嘧啶区域长度
+}
+
+// DefaultSpliceLens 默认剪接区域长度
+var DefaultSpliceLens = SpliceLens{Site: 2, RegionExon: 3, RegionIntron: 8, Polypyrimidine: 17}
+
+// IntronDistance pos在intron中距离5'端(供体)及3'端(受体)的距离，从1开始计数
+func (this Region) IntronDistance(pos int, strand string) (int, int) {
+	dist1, dist2 := pos-this.Start+1, this.End-pos+1
+	if strand == "+" {
+		return dist1, dist2
+	}
+	return dist2, dist1
+}
+
+// spliceTypesAt pos在转录本上的剪接区域类型
+func (this Transcript) spliceTypesAt(pos int, lens SpliceLens) []string {
+	types := make([]string, 0)
+	region, _, _ := this.Region(pos)
+	if !region.Exists() {
+		return types
+	}
+	if region.Type == RType_INTRON {
+		donor, acceptor := region.IntronDistance(pos, this.Strand)
+		if donor <= lens.Site {
+			types = append(types, SType_DONOR)
+		}
+		if acceptor <= lens.Site {
+			types = append(types, SType_ACCEPTOR)
+		}
+		if donor == 5 {
+			types = append(types, SType_DONOR_5TH_BASE)
+		}
+		if (donor > lens.Site && donor <= lens.RegionIntron) || (acceptor > lens.Site && acceptor <= lens.RegionIntron) {
+			types = append(types, SType_REGION)
+		}
+		if acceptor > lens.Site && acceptor <= lens.Polypyrimidine {
+			types = append(types, SType_POLYPYRIMIDINE)
+		}
+		return types
+	}
+	for i := 0; i < this.ExonCount; i++ {
+		start, end := this.ExonStarts[i], this.ExonEnds[i]
+		if start <= pos && pos <= end {
+			if (i > 0 && pos-start+1 <= lens.RegionExon) || (i < this.ExonCount-1 && end-pos+1 <= lens.RegionExon) {
+				types = append(types, SType_REGION)
+			}
+			break
+		}
+	}
+	return types
+}
+
+// SpliceTypes 变异在转录本上的剪接区域类型，如 splice_donor、splice_region
+func (this Transcript) SpliceTypes(variant AnnoVariant, lens SpliceLens) []string {
+	types := make([]string, 0)
+	if variant.Ref == "-" {
+		// 插入位于 Start 与 Start+1 之间，供体/受体位点要求两侧碱基均位于其中
+		types1, types2 := this.spliceTypesAt(variant.Start, lens), this.spliceTypesAt(variant.Start+1, lens)
+		for _, stype := range append(types1, types2...) {
+			if FindArr(types, stype) >= 0 {
+				continue
+			}
+			if (stype == SType_DONOR || stype == SType_ACCEPTOR) && (FindArr(types1, stype) < 0 || FindArr(types2, stype) < 0) {
+				stype = SType_REGION
+				if FindArr(types, stype) >= 0 {
+					continue
+				}
+			}
+			types = append(types, stype)
+		}
+		return types
+	}
+	for pos := variant.Start; pos <= variant.End; pos++ {
+		for _, stype := range this.spliceTypesAt(pos, lens) {
+			if FindArr(types, stype) < 0 {
+				types = append(types, stype)
+			}
+		}
+	}
+	return types
+}
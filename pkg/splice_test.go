@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpliceTypes(t *testing.T) {
+	// exon 1001-1200, 1401-1600, 1801-2000，intron 1201-1400, 1601-1800
+	tests := []struct {
+		name    string
+		strand  string
+		variant AnnoVariant
+		lens    SpliceLens
+		want    string
+	}{
+		{"plus donor first base", "+", AnnoVariant{Start: 1201, End: 1201, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_donor"},
+		{"plus donor last base", "+", AnnoVariant{Start: 1202, End: 1202, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_donor"},
+		{"plus intron region after donor", "+", AnnoVariant{Start: 1203, End: 1203, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"plus donor 5th base", "+", AnnoVariant{Start: 1205, End: 1205, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_donor_5th_base,splice_region"},
+		{"plus intron region end", "+", AnnoVariant{Start: 1208, End: 1208, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"plus deep intron", "+", AnnoVariant{Start: 1209, End: 1209, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus acceptor last base", "+", AnnoVariant{Start: 1400, End: 1400, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_acceptor"},
+		{"plus acceptor first base", "+", AnnoVariant{Start: 1399, End: 1399, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_acceptor"},
+		{"plus region and polypyrimidine", "+", AnnoVariant{Start: 1398, End: 1398, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region,splice_polypyrimidine_tract"},
+		{"plus intron region before acceptor", "+", AnnoVariant{Start: 1393, End: 1393, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region,splice_polypyrimidine_tract"},
+		{"plus polypyrimidine only", "+", AnnoVariant{Start: 1392, End: 1392, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_polypyrimidine_tract"},
+		{"plus polypyrimidine start", "+", AnnoVariant{Start: 1384, End: 1384, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_polypyrimidine_tract"},
+		{"plus before polypyrimidine", "+", AnnoVariant{Start: 1383, End: 1383, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus exon end region", "+", AnnoVariant{Start: 1198, End: 1198, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"plus exon before region", "+", AnnoVariant{Start: 1197, End: 1197, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus exon start region", "+", AnnoVariant{Start: 1403, End: 1403, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"plus exon after region", "+", AnnoVariant{Start: 1404, End: 1404, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus transcript start", "+", AnnoVariant{Start: 1001, End: 1001, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus transcript end", "+", AnnoVariant{Start: 2000, End: 2000, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus outside transcript", "+", AnnoVariant{Start: 900, End: 900, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"plus custom lens", "+", AnnoVariant{Start: 1202, End: 1202, Ref: "A", Alt: "G"}, SpliceLens{Site: 1, RegionExon: 1, RegionIntron: 3, Polypyrimidine: 5}, "splice_region"},
+		{"plus deletion across exon/intron", "+", AnnoVariant{Start: 1199, End: 1202, Ref: "AAAA", Alt: "-"}, DefaultSpliceLens, "splice_region,splice_donor"},
+		{"plus insertion before donor", "+", AnnoVariant{Start: 1200, End: 1200, Ref: "-", Alt: "A"}, DefaultSpliceLens, "splice_region"},
+		{"plus insertion in donor", "+", AnnoVariant{Start: 1201, End: 1201, Ref: "-", Alt: "A"}, DefaultSpliceLens, "splice_donor"},
+		{"plus insertion after donor", "+", AnnoVariant{Start: 1202, End: 1202, Ref: "-", Alt: "A"}, DefaultSpliceLens, "splice_region"},
+		{"plus insertion in acceptor", "+", AnnoVariant{Start: 1399, End: 1399, Ref: "-", Alt: "A"}, DefaultSpliceLens, "splice_acceptor"},
+		{"minus donor first base", "-", AnnoVariant{Start: 1400, End: 1400, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_donor"},
+		{"minus donor last base", "-", AnnoVariant{Start: 1399, End: 1399, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_donor"},
+		{"minus donor 5th base", "-", AnnoVariant{Start: 1396, End: 1396, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_donor_5th_base,splice_region"},
+		{"minus intron region end", "-", AnnoVariant{Start: 1393, End: 1393, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"minus deep intron", "-", AnnoVariant{Start: 1392, End: 1392, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"minus acceptor last base", "-", AnnoVariant{Start: 1201, End: 1201, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_acceptor"},
+		{"minus acceptor first base", "-", AnnoVariant{Start: 1202, End: 1202, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_acceptor"},
+		{"minus region and polypyrimidine", "-", AnnoVariant{Start: 1203, End: 1203, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region,splice_polypyrimidine_tract"},
+		{"minus polypyrimidine only", "-", AnnoVariant{Start: 1209, End: 1209, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_polypyrimidine_tract"},
+		{"minus polypyrimidine start", "-", AnnoVariant{Start: 1217, End: 1217, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_polypyrimidine_tract"},
+		{"minus before polypyrimidine", "-", AnnoVariant{Start: 1218, End: 1218, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"minus exon region", "-", AnnoVariant{Start: 1401, End: 1401, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"minus exon region end", "-", AnnoVariant{Start: 1198, End: 1198, Ref: "A", Alt: "G"}, DefaultSpliceLens, "splice_region"},
+		{"minus transcript start", "-", AnnoVariant{Start: 2000, End: 2000, Ref: "A", Alt: "G"}, DefaultSpliceLens, ""},
+		{"minus insertion before donor", "-", AnnoVariant{Start: 1400, End: 1400, Ref: "-", Alt: "A"}, DefaultSpliceLens, "splice_region"},
+		{"minus insertion in donor", "-", AnnoVariant{Start: 1399, End: 1399, Ref: "-", Alt: "A"}, DefaultSpliceLens, "splice_donor"},
+	}
+	for _, test := range tests {
+		line := "0\tNM_SPLICE\tchr1\t" + test.strand + "\t1000\t2000\t1100\t1900\t3\t1000,1400,1800,\t1200,1600,2000,\t0\tGENE\tcmpl\tcmpl\t0,1,2,"
+		trans, err := NewTranscript(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.SetRegions()
+		test.variant.Chrom = "chr1"
+		types := trans.SpliceTypes(test.variant, test.lens)
+		if strings.Join(types, ",") != test.want {
+			t.Errorf("%s: got %v, want %s", test.name, types, test.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+)
+
+const (
+	TabixFormat_GENERIC = 0
+	TabixFormat_VCF     = 2
+)
+
+// TabixConf tabix索引配置，列序号从1开始
+type TabixConf struct {
+	Format    int32
+	SeqCol    int32
+	BegCol    int32
+	EndCol    int32
+	ZeroBased bool
+	Meta      byte
+}
+
+var (
+	TabixGenePred = TabixConf{Format: TabixFormat_GENERIC, SeqCol: 3, BegCol: 5, EndCol: 6, ZeroBased: true, Meta: '#'}
+	TabixBed      = TabixConf{Format: TabixFormat_GENERIC, SeqCol: 1, BegCol: 2, EndCol: 3, ZeroBased: true, Meta: '#'}
+	TabixVCF      = TabixConf{Format: TabixFormat_VCF, SeqCol: 1, BegCol: 2, EndCol: 0, Meta: '#'}
+)
+
+// Reg2Bin 区间[beg, end)所在的bin，与UCSC及SAM的分箱方案一致
+func Reg2Bin(beg, end int) int {
+	end--
+	switch {
+	case beg>>14 == end>>14:
+		return ((1<<15)-1)/7 + (beg >> 14)
+	case beg>>17 == end>>17:
+		return ((1<<12)-1)/7 + (beg >> 17)
+	case beg>>20 == end>>20:
+		return ((1<<9)-1)/7 + (beg >> 20)
+	case beg>>23 == end>>23:
+		return ((1<<6)-1)/7 + (beg >> 23)
+	case beg>>26 == end>>26:
+		return ((1<<3)-1)/7 + (beg >> 26)
+	}
+	return 0
+}
+
+// ChromLess 染色体自然排序，chr2 排在 chr10 之前
+func ChromLess(chrom1, chrom2 string) bool {
+	name1, name2 := strings.TrimPrefix(chrom1, "chr"), strings.TrimPrefix(chrom2, "chr")
+	num1, err1 := strconv.Atoi(name1)
+	num2, err2 := strconv.Atoi(name2)
+	if err1 == nil && err2 == nil {
+		return num1 < num2
+	}
+	if err1 == nil || err2 == nil {
+		return err1 == nil
+	}
+	return name1 < name2
+}
+
+type countWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (this *countWriter) Write(b []byte) (int, error) {
+	n, err := this.writer.Write(b)
+	this.count += int64(n)
+	return n, err
+}
+
+type tabixChunk struct {
+	begin, end uint64
+}
+
+type tabixRef struct {
+	name   string
+	bins   map[uint32][]tabixChunk
+	order  []uint32
+	linear []uint64
+}
+
+// add 记录一行的bin与线性索引
+func (this *tabixRef) add(beg, end int, begin, stop uint64) {
+	bin := uint32(Reg2Bin(beg, end))
+	chunks, ok := this.bins[bin]
+	if !ok {
+		this.order = append(this.order, bin)
+	}
+	if len(chunks) > 0 && chunks[len(chunks)-1].end == begin {
+		chunks[len(chunks)-1].end = stop
+	} else {
+		chunks = append(chunks, tabixChunk{begin: begin, end: stop})
+	}
+	this.bins[bin] = chunks
+	for i := beg >> 14; i <= (end-1)>>14; i++ {
+		for len(this.linear) <= i {
+			this.linear = append(this.linear, 0)
+		}
+		if this.linear[i] == 0 {
+			this.linear[i] = begin
+		}
+	}
+}
+
+// lineRange 根据配置解析一行的染色体和0-based半开区间
+func (this TabixConf) lineRange(line string) (string, int, int, error) {
+	row := strings.Split(line, "\t")
+	if int(this.SeqCol) > len(row) || int(this.BegCol) > len(row) || int(this.EndCol) > len(row) {
+		return "", 0, 0, fmt.Errorf("tabix: too few columns: %s", line)
+	}
+	beg, err := strconv.Atoi(row[this.BegCol-1])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	end := beg
+	if this.Format == TabixFormat_VCF {
+		end = beg + len(row[3]) - 1
+	} else if this.EndCol > 0 {
+		end, err = strconv.Atoi(row[this.EndCol-1])
+		if err != nil {
+			return "", 0, 0, err
+		}
+	}
+	if !this.ZeroBased {
+		beg--
+	}
+	if end <= beg {
+		end = beg + 1
+	}
+	return row[this.SeqCol-1], beg, end, nil
+}
+
+// WriteTabix 将已按染色体和起始位置排序的行写入bgzip文件，并生成tabix索引(.tbi)
+func WriteTabix(outfile string, headers []string, lines []string, conf TabixConf) error {
+	fi, err := os.Create(outfile)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	counter := &countWriter{writer: fi}
+	writer := bgzf.NewWriter(counter, 1)
+	var blockStart int64
+	var blockLen int
+	write := func(text string) (uint64, uint64, error) {
+		if blockLen > 0 && blockLen+len(text) >= bgzf.BlockSize {
+			if err := writer.Flush(); err != nil {
+				return 0, 0, err
+			}
+			if err := writer.Wait(); err != nil {
+				return 0, 0, err
+			}
+			blockStart, blockLen = counter.count, 0
+		}
+		begin := uint64(blockStart)<<16 | uint64(blockLen)
+		if _, err := writer.Write([]byte(text)); err != nil {
+			return 0, 0, err
+		}
+		blockLen += len(text)
+		if blockLen >= bgzf.BlockSize {
+			if err := writer.Flush(); err != nil {
+				return 0, 0, err
+			}
+			if err := writer.Wait(); err != nil {
+				return 0, 0, err
+			}
+			blockStart, blockLen = counter.count, 0
+		}
+		return begin, uint64(blockStart)<<16 | uint64(blockLen), nil
+	}
+	for _, header := range headers {
+		if _, _, err := write(header + "\n"); err != nil {
+			return err
+		}
+	}
+	refs := make([]*tabixRef, 0)
+	for _, line := range lines {
+		chrom, beg, end, err := conf.lineRange(line)
+		if err != nil {
+			return err
+		}
+		begin, stop, err := write(line + "\n")
+		if err != nil {
+			return err
+		}
+		if len(refs) == 0 || refs[len(refs)-1].name != chrom {
+			for _, ref := range refs {
+				if ref.name == chrom {
+					return fmt.Errorf("tabix: %s is not continuous, input must be sorted", chrom)
+				}
+			}
+			refs = append(refs, &tabixRef{name: chrom, bins: make(map[uint32][]tabixChunk)})
+		}
+		refs[len(refs)-1].add(beg, end, begin, stop)
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return writeTabixIndex(outfile+".tbi", refs, conf)
+}
+
+// writeTabixIndex 写入tabix索引文件
+func writeTabixIndex(outfile string, refs []*tabixRef, conf TabixConf) error {
+	fi, err := os.Create(outfile)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	writer := bgzf.NewWriter(fi, 1)
+	names := make([]string, len(refs))
+	for i, ref := range refs {
+		names[i] = ref.name
+	}
+	nameText := strings.Join(names, "\x00") + "\x00"
+	format := conf.Format
+	if conf.ZeroBased {
+		format |= 0x10000
+	}
+	endCol := conf.EndCol
+	if endCol == 0 {
+		endCol = conf.BegCol
+	}
+	data := []any{[]byte("TBI\x01"), int32(len(refs)), format, conf.SeqCol, conf.BegCol, endCol, int32(conf.Meta), int32(0), int32(len(nameText)), []byte(nameText)}
+	for _, ref := range refs {
+		sort.Slice(ref.order, func(i, j int) bool { return ref.order[i] < ref.order[j] })
+		data = append(data, int32(len(ref.order)))
+		for _, bin := range ref.order {
+			data = append(data, bin, int32(len(ref.bins[bin])))
+			for _, chunk := range ref.bins[bin] {
+				data = append(data, chunk.begin, chunk.end)
+			}
+		}
+		for i := 1; i < len(ref.linear); i++ {
+			if ref.linear[i] == 0 {
+				ref.linear[i] = ref.linear[i-1]
+			}
+		}
+		data = append(data, int32(len(ref.linear)), ref.linear)
+	}
+	for _, val := range data {
+		if err = binary.Write(writer, binary.LittleEndian, val); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -9,13 +9,15 @@ import (
 	"github.com/brentp/faidx"
 )
 
-var GeneSymbolToID map[string]map[string]string
+// GeneSymbols 染色体及基因Symbol对应的EntrezID
+type GeneSymbols map[string]map[string]string
 
-func InitGeneSymbolToID(infile string) error {
-	gene := make(map[string]map[string]string)
+// ReadGeneSymbols 读取基因Symbol与EntrezID对应关系, FORMAT=Chrom\tSymbol\tEntrezId
+func ReadGeneSymbols(infile string) (GeneSymbols, error) {
+	gene := make(GeneSymbols)
 	reader, err := NewIOReader(infile)
 	if err != nil {
-		return err
+		return gene, err
 	}
 	defer reader.Close()
 	scanner := NewCSVScanner(reader)
@@ -31,26 +33,40 @@ func InitGeneSymbolToID(infile string) error {
 		gene[chrom][symbol] = entrezId
 
 	}
-	GeneSymbolToID = gene
-	return nil
+	return gene, nil
 }
 
 // Transcript 转录本，继承自GenePred，加入GeneID和Regions信息
 type Transcript struct {
-	Name       string  `json:"name"`
-	Chrom      string  `json:"chrom"`
-	Strand     string  `json:"strand"`
-	TxStart    int     `json:"txStart"`
-	TxEnd      int     `json:"txEnd"`
-	CdsStart   int     `json:"cdsStart"`
-	CdsEnd     int     `json:"cdsEnd"`
-	ExonCount  int     `json:"exonCount"`
-	ExonStarts []int   `json:"exonStarts"`
-	ExonEnds   []int   `json:"exonEnds"`
-	Gene       string  `json:"gene"`
-	CdsStat    string  `json:"cdsStartStat"`
-	GeneID     string  `json:"gene_id"`
-	Regions    Regions `json:"regions"`
+	Name       string   `json:"name"`
+	Chrom      string   `json:"chrom"`
+	Strand     string   `json:"strand"`
+	TxStart    int      `json:"txStart"`
+	TxEnd      int      `json:"txEnd"`
+	CdsStart   int      `json:"cdsStart"`
+	CdsEnd     int      `json:"cdsEnd"`
+	ExonCount  int      `json:"exonCount"`
+	ExonStarts []int    `json:"exonStarts"`
+	ExonEnds   []int    `json:"exonEnds"`
+	Gene       string   `json:"gene"`
+	CdsStat    string   `json:"cdsStartStat"`
+	CdsStartNF bool     `json:"cds_start_NF"`
+	CdsEndNF   bool     `json:"cds_end_NF"`
+	Biotype    string   `json:"biotype"`
+	Tags       []string `json:"tags"`
+	SourceID   string   `json:"source_gene_id"`
+	GeneID     string   `json:"gene_id"`
+	Regions    Regions  `json:"regions"`
+}
+
+// HasTag 转录本是否带有指定标签，如 MANE_Select, basic, CCDS
+func (this Transcript) HasTag(tag string) bool {
+	for _, t := range this.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 // PK GenePred主键名称
@@ -63,6 +79,30 @@ func (this Transcript) IsUnk() bool {
 	return this.CdsEnd-this.CdsStart+1 == 0
 }
 
+const (
+	Biotype_PROTEIN_CODING = "protein_coding"
+	Biotype_NCRNA          = "ncRNA"
+	Biotype_MIRNA          = "miRNA"
+	Biotype_MATURE_MIRNA   = "mature_miRNA"
+	Biotype_LNCRNA         = "lncRNA"
+)
+
+// TransBiotype 转录本biotype，GenePred中无biotype时根据是否编码推断
+func (this Transcript) TransBiotype() string {
+	if this.Biotype != "" && this.Biotype != "." {
+		return this.Biotype
+	}
+	if this.IsUnk() {
+		return Biotype_NCRNA
+	}
+	return Biotype_PROTEIN_CODING
+}
+
+// IsMatureMiRNA 是否为成熟miRNA
+func (this Transcript) IsMatureMiRNA() bool {
+	return this.TransBiotype() == Biotype_MATURE_MIRNA
+}
+
 // HasUTR3 存在UTR3区域
 func (this Transcript) HasUTR3() bool {
 	if this.Strand == "+" {
@@ -133,11 +173,64 @@ func (this Transcript) Region(pos int) (Region, int, int) {
 	return region, cLen, uLen
 }
 
-// SetGeneID 根据geneSymbolToID的Map信息设置转录本的GeneID
-func (this *Transcript) SetGeneID() {
+// Codon 位置在转录本CDS中所在密码子的序号，从1开始，不在CDS中时返回false
+func (this Transcript) Codon(pos int) (int, bool) {
+	region, cLen, _ := this.Region(pos)
+	if region.Type != RType_CDS {
+		return 0, false
+	}
+	cpos := cLen + pos - region.Start + 1
+	if this.Strand == "-" {
+		cpos = this.CLen() - cpos + 1
+	}
+	return (cpos-1)/3 + 1, true
+}
+
+// formatNumbers 将编号范围格式化为 3/12 或 3-4/12
+func formatNumbers(first, last, total int) string {
+	if first == 0 {
+		return ""
+	}
+	if first == last {
+		return fmt.Sprintf("%d/%d", first, total)
+	}
+	return fmt.Sprintf("%d-%d/%d", first, last, total)
+}
+
+// ExonIntron 变异所在的外显子与内含子编号，如 3/12, 2/11
+func (this Transcript) ExonIntron(variant AnnoVariant) (string, string) {
+	var exon1, exon2, intron1, intron2 int
+	update := func(num int, first, last *int) {
+		if *first == 0 || num < *first {
+			*first = num
+		}
+		if num > *last {
+			*last = num
+		}
+	}
+	for _, region := range this.Regions {
+		if region.End < variant.Start || region.Start > variant.End {
+			continue
+		}
+		if region.Type == RType_INTRON {
+			update(region.Order, &intron1, &intron2)
+		} else {
+			num, err := strconv.Atoi(strings.TrimPrefix(region.Exon, "exon"))
+			if err == nil {
+				update(num, &exon1, &exon2)
+			}
+		}
+	}
+	return formatNumbers(exon1, exon2, this.ExonCount), formatNumbers(intron1, intron2, this.ExonCount-1)
+}
+
+// SetGeneID 根据GeneSymbols信息设置转录本的GeneID
+func (this *Transcript) SetGeneID(symbols GeneSymbols) {
 	this.GeneID = "."
-	if entrezId, ok := GeneSymbolToID[this.Chrom][this.Gene]; ok {
+	if entrezId, ok := symbols[this.Chrom][this.Gene]; ok {
 		this.GeneID = entrezId
+	} else if this.SourceID != "" && this.SourceID != "." {
+		this.GeneID = this.SourceID
 	}
 }
 
@@ -158,14 +251,14 @@ func (this *Transcript) SetRegionsWithSeq(genome *faidx.Faidx) error {
 	return nil
 }
 
-// NewGenePred 从GenePred文件中读取一行并解析为GenePred对象
+// NewTranscript 从GenePred文件中读取一行并解析为Transcript对象，19列为 pre gtf 输出的扩展格式(追加 gene_id、biotype、tags)
 func NewTranscript(line string) (Transcript, error) {
 	row := strings.Split(line, "\t")
 	var trans Transcript
 	var name, chrom, strand, txStart, txEnd, cdsStart, cdsEnd, exonCount, gene string
 	var exonStarts, exonEnds []string
 	switch len(row) {
-	case 16:
+	case 16, 19:
 		name = row[1]
 		chrom = row[2]
 		strand = row[3]
@@ -177,6 +270,19 @@ func NewTranscript(line string) (Transcript, error) {
 		exonStarts = strings.Split(strings.Trim(row[9], ","), ",")
 		exonEnds = strings.Split(strings.Trim(row[10], ","), ",")
 		gene = row[12]
+		trans.CdsStat = row[13]
+		// cds_start_NF/cds_end_NF 为转录方向，GenePred中为基因组方向
+		trans.CdsStartNF, trans.CdsEndNF = row[13] == "incmpl", row[14] == "incmpl"
+		if strand == "-" {
+			trans.CdsStartNF, trans.CdsEndNF = trans.CdsEndNF, trans.CdsStartNF
+		}
+		if len(row) == 19 {
+			trans.SourceID = row[16]
+			trans.Biotype = row[17]
+			if row[18] != "." && row[18] != "" {
+				trans.Tags = strings.Split(row[18], ",")
+			}
+		}
 	case 12:
 		name = row[0]
 		chrom = row[1]
@@ -234,3 +340,108 @@ func NewTranscript(line string) (Transcript, error) {
 	}
 	return trans, err
 }
+
+// inSameRegions 判断变异位置是否仍位于原Region中
+func (this Transcript) inSameRegions(start, end int, region1, region2 Region) bool {
+	nregion1, _, _ := this.Region(start)
+	nregion2, _, _ := this.Region(end)
+	return nregion1.Equal(region1) && nregion2.Equal(region2)
+}
+
+// ShiftVariant 根据转录本方向将Indel向3'端移动(HGVS 3'原则)，返回移动后的变异、移动碱基数及是否因跨越exon/intron边界而停止移动
+func (this Transcript) ShiftVariant(variant AnnoVariant) (AnnoVariant, int, bool) {
+	seq := this.DNA()
+	if len(seq) != this.TxEnd-this.TxStart+1 || variant.Ref != "-" && variant.Alt != "-" {
+		return variant, 0, false
+	}
+	var offset int
+	var crossed bool
+	if variant.Ref == "-" {
+		// 插入位于 seq[pos-1] 与 seq[pos] 之间
+		pos, alt := variant.Start-this.TxStart+1, variant.Alt
+		region1, _, _ := this.Region(variant.Start)
+		region2, _, _ := this.Region(variant.Start + 1)
+		for {
+			if this.Strand == "+" {
+				if pos >= len(seq) || pos < 0 || seq[pos] != alt[0] {
+					break
+				}
+				if !this.inSameRegions(pos+this.TxStart, pos+this.TxStart+1, region1, region2) {
+					crossed = true
+					break
+				}
+				alt = alt[1:] + alt[0:1]
+				pos++
+			} else {
+				if pos <= 0 || pos > len(seq) || seq[pos-1] != alt[len(alt)-1] {
+					break
+				}
+				if !this.inSameRegions(pos+this.TxStart-2, pos+this.TxStart-1, region1, region2) {
+					crossed = true
+					break
+				}
+				alt = alt[len(alt)-1:] + alt[0:len(alt)-1]
+				pos--
+			}
+			offset++
+		}
+		variant.Start = pos + this.TxStart - 1
+		variant.End = variant.Start
+		variant.Alt = alt
+	} else {
+		// 缺失区域为 seq[start:end]
+		start, end := variant.Start-this.TxStart, variant.End-this.TxStart+1
+		if start < 0 || end > len(seq) {
+			return variant, 0, false
+		}
+		region1, _, _ := this.Region(variant.Start)
+		region2, _, _ := this.Region(variant.End)
+		for {
+			if this.Strand == "+" {
+				if end >= len(seq) || seq[start] != seq[end] {
+					break
+				}
+				if !this.inSameRegions(start+this.TxStart+1, end+this.TxStart, region1, region2) {
+					crossed = true
+					break
+				}
+				start++
+				end++
+			} else {
+				if start <= 0 || seq[start-1] != seq[end-1] {
+					break
+				}
+				if !this.inSameRegions(start+this.TxStart-1, end+this.TxStart-2, region1, region2) {
+					crossed = true
+					break
+				}
+				start--
+				end--
+			}
+			offset++
+		}
+		variant.Start = start + this.TxStart
+		variant.End = end + this.TxStart - 1
+		variant.Ref = seq[start:end]
+	}
+	return variant, offset, crossed
+}
+
+// DupRange 插入序列与其在转录本方向5'端相邻序列相同时，返回被重复序列的基因组区间
+func (this Transcript) DupRange(variant AnnoVariant) (int, int, bool) {
+	seq := this.DNA()
+	if len(seq) != this.TxEnd-this.TxStart+1 || variant.Ref != "-" {
+		return 0, 0, false
+	}
+	pos, length := variant.Start-this.TxStart+1, len(variant.Alt)
+	if this.Strand == "+" {
+		if pos-length >= 0 && pos <= len(seq) && seq[pos-length:pos] == variant.Alt {
+			return variant.Start - length + 1, variant.Start, true
+		}
+	} else {
+		if pos >= 0 && pos+length <= len(seq) && seq[pos:pos+length] == variant.Alt {
+			return variant.Start + 1, variant.Start + length, true
+		}
+	}
+	return 0, 0, false
+}
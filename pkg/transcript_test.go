@@ -0,0 +1,78 @@
+package pkg
+
+import "testing"
+
+// 转录本chr1:101-130，exon 101-110, 121-130，CDS 103-128
+const testTransSeq = "ACGTAAACAG" + "GTAAGTCCAG" + "CTCTCTGATT"
+
+func newTestShiftTranscript(t *testing.T, strand string) Transcript {
+	line := "0\tNM_SHIFT\tchr1\t" + strand + "\t100\t130\t102\t128\t2\t100,120,\t110,130,\t0\tGENE\tcmpl\tcmpl\t0,2,"
+	trans, err := NewTranscript(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trans.Regions = NewRegionsWithSeq(trans, testTransSeq)
+	return trans
+}
+
+func TestShiftVariant(t *testing.T) {
+	tests := []struct {
+		name    string
+		strand  string
+		variant AnnoVariant
+		want    AnnoVariant
+		offset  int
+		crossed bool
+	}{
+		{"plus deletion in homopolymer", "+", AnnoVariant{Start: 105, End: 105, Ref: "A", Alt: "-"}, AnnoVariant{Start: 107, End: 107, Ref: "A", Alt: "-"}, 2, false},
+		{"plus insertion in homopolymer", "+", AnnoVariant{Start: 105, End: 105, Ref: "-", Alt: "A"}, AnnoVariant{Start: 107, End: 107, Ref: "-", Alt: "A"}, 2, false},
+		{"plus deletion in repeat", "+", AnnoVariant{Start: 121, End: 122, Ref: "CT", Alt: "-"}, AnnoVariant{Start: 125, End: 126, Ref: "CT", Alt: "-"}, 4, false},
+		{"plus insertion rotates", "+", AnnoVariant{Start: 121, End: 121, Ref: "-", Alt: "TC"}, AnnoVariant{Start: 126, End: 126, Ref: "-", Alt: "CT"}, 5, false},
+		{"plus insertion at intron/exon junction", "+", AnnoVariant{Start: 120, End: 120, Ref: "-", Alt: "CT"}, AnnoVariant{Start: 120, End: 120, Ref: "-", Alt: "CT"}, 0, true},
+		{"plus stop at exon end", "+", AnnoVariant{Start: 110, End: 110, Ref: "G", Alt: "-"}, AnnoVariant{Start: 110, End: 110, Ref: "G", Alt: "-"}, 0, true},
+		{"plus stop at transcript end", "+", AnnoVariant{Start: 129, End: 129, Ref: "T", Alt: "-"}, AnnoVariant{Start: 130, End: 130, Ref: "T", Alt: "-"}, 1, false},
+		{"plus insertion at transcript end", "+", AnnoVariant{Start: 130, End: 130, Ref: "-", Alt: "T"}, AnnoVariant{Start: 130, End: 130, Ref: "-", Alt: "T"}, 0, false},
+		{"minus deletion in homopolymer", "-", AnnoVariant{Start: 106, End: 106, Ref: "A", Alt: "-"}, AnnoVariant{Start: 105, End: 105, Ref: "A", Alt: "-"}, 1, false},
+		{"minus insertion in homopolymer", "-", AnnoVariant{Start: 107, End: 107, Ref: "-", Alt: "A"}, AnnoVariant{Start: 104, End: 104, Ref: "-", Alt: "A"}, 3, false},
+		{"minus deletion in repeat", "-", AnnoVariant{Start: 125, End: 126, Ref: "CT", Alt: "-"}, AnnoVariant{Start: 121, End: 122, Ref: "CT", Alt: "-"}, 4, false},
+		{"minus stop at intron start", "-", AnnoVariant{Start: 111, End: 111, Ref: "G", Alt: "-"}, AnnoVariant{Start: 111, End: 111, Ref: "G", Alt: "-"}, 0, true},
+		{"minus stop at transcript start", "-", AnnoVariant{Start: 101, End: 101, Ref: "A", Alt: "-"}, AnnoVariant{Start: 101, End: 101, Ref: "A", Alt: "-"}, 0, false},
+		{"snp is not shifted", "+", AnnoVariant{Start: 105, End: 105, Ref: "A", Alt: "G"}, AnnoVariant{Start: 105, End: 105, Ref: "A", Alt: "G"}, 0, false},
+	}
+	for _, test := range tests {
+		trans := newTestShiftTranscript(t, test.strand)
+		test.variant.Chrom, test.want.Chrom = "chr1", "chr1"
+		variant, offset, crossed := trans.ShiftVariant(test.variant)
+		if variant != test.want || offset != test.offset || crossed != test.crossed {
+			t.Errorf("%s: got %+v %d %v, want %+v %d %v", test.name, variant, offset, crossed, test.want, test.offset, test.crossed)
+		}
+	}
+}
+
+func TestDupRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		strand     string
+		variant    AnnoVariant
+		start, end int
+		ok         bool
+	}{
+		{"plus dup", "+", AnnoVariant{Start: 107, End: 107, Ref: "-", Alt: "AA"}, 106, 107, true},
+		{"plus dup of repeat unit", "+", AnnoVariant{Start: 126, End: 126, Ref: "-", Alt: "CT"}, 125, 126, true},
+		{"plus not dup", "+", AnnoVariant{Start: 107, End: 107, Ref: "-", Alt: "GG"}, 0, 0, false},
+		{"plus dup beyond transcript start", "+", AnnoVariant{Start: 101, End: 101, Ref: "-", Alt: "AA"}, 0, 0, false},
+		{"minus dup", "-", AnnoVariant{Start: 104, End: 104, Ref: "-", Alt: "AA"}, 105, 106, true},
+		{"minus dup of repeat unit", "-", AnnoVariant{Start: 120, End: 120, Ref: "-", Alt: "CT"}, 121, 122, true},
+		{"minus not dup", "-", AnnoVariant{Start: 107, End: 107, Ref: "-", Alt: "AA"}, 0, 0, false},
+		{"minus dup beyond transcript end", "-", AnnoVariant{Start: 129, End: 129, Ref: "-", Alt: "TT"}, 0, 0, false},
+		{"deletion is not dup", "+", AnnoVariant{Start: 106, End: 107, Ref: "AA", Alt: "-"}, 0, 0, false},
+	}
+	for _, test := range tests {
+		trans := newTestShiftTranscript(t, test.strand)
+		test.variant.Chrom = "chr1"
+		start, end, ok := trans.DupRange(test.variant)
+		if start != test.start || end != test.end || ok != test.ok {
+			t.Errorf("%s: got %d %d %v, want %d %d %v", test.name, start, end, ok, test.start, test.end, test.ok)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/brentp/irelate/interfaces"
@@ -99,6 +100,126 @@ func (this *SNV) AnnoVariant() AnnoVariant {
 	return AnnoVariant{Chrom: chrom, Start: start, End: end, Ref: ref, Alt: alt}
 }
 
+// splitInfo 拆分INFO文本中Number=A/R的字段，只保留第i个ALT对应的值
+func splitInfo(info string, header *vcfgo.Header, i int, n int) string {
+	if info == "" || info == "." || header == nil {
+		return info
+	}
+	fields := strings.Split(info, ";")
+	for j, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		headerInfo, ok := header.Infos[kv[0]]
+		if !ok {
+			continue
+		}
+		vals := strings.Split(kv[1], ",")
+		if headerInfo.Number == "A" && len(vals) == n {
+			fields[j] = fmt.Sprintf("%s=%s", kv[0], vals[i])
+		} else if headerInfo.Number == "R" && len(vals) == n+1 {
+			fields[j] = fmt.Sprintf("%s=%s,%s", kv[0], vals[0], vals[i+1])
+		}
+	}
+	return strings.Join(fields, ";")
+}
+
+// splitSample 拆分样本基因型，同bcftools norm -m-：第i个ALT记为1，其他ALT记为0，缺失保持不变；
+// Number=A/R/G的FORMAT字段只保留对应等位基因的值
+func splitSample(sample *vcfgo.SampleGenotype, header *vcfgo.Header, i int, n int) *vcfgo.SampleGenotype {
+	if sample == nil {
+		return nil
+	}
+	geno := *sample
+	geno.GT = make([]int, len(sample.GT))
+	gts := make([]string, len(sample.GT))
+	for j, gt := range sample.GT {
+		switch {
+		case gt < 0:
+			geno.GT[j], gts[j] = -1, "."
+		case gt == i+1:
+			geno.GT[j], gts[j] = 1, "1"
+		default:
+			geno.GT[j], gts[j] = 0, "0"
+		}
+	}
+	geno.Fields = make(map[string]string, len(sample.Fields))
+	for key, value := range sample.Fields {
+		geno.Fields[key] = value
+		if header == nil {
+			continue
+		}
+		format, ok := header.SampleFormats[key]
+		if !ok {
+			continue
+		}
+		vals := strings.Split(value, ",")
+		switch {
+		case format.Number == "A" && len(vals) == n:
+			geno.Fields[key] = vals[i]
+		case format.Number == "R" && len(vals) == n+1:
+			geno.Fields[key] = fmt.Sprintf("%s,%s", vals[0], vals[i+1])
+		case format.Number == "G" && len(sample.GT) == 1 && len(vals) == n+1:
+			geno.Fields[key] = fmt.Sprintf("%s,%s", vals[0], vals[i+1])
+		case format.Number == "G" && len(sample.GT) == 2 && len(vals) == (n+1)*(n+2)/2:
+			// 二倍体基因型j/k的序号为k*(k+1)/2+j，保留0/0、0/a、a/a
+			a := i + 1
+			geno.Fields[key] = fmt.Sprintf("%s,%s,%s", vals[0], vals[a*(a+1)/2], vals[a*(a+1)/2+a])
+		}
+	}
+	if _, ok := sample.Fields["GT"]; ok {
+		sep := "/"
+		if sample.Phased {
+			sep = "|"
+		}
+		geno.Fields["GT"] = strings.Join(gts, sep)
+	}
+	return &geno
+}
+
+// SplitAlts 将多等位位点拆分为每个ALT一条记录，Number=A/R的INFO字段按等位基因拆分，
+// 样本基因型及FORMAT字段按splitSample拆分，
+// 并在INFO中以MULTIALLELIC记录原始位点，FORMAT=Chrom:Pos:Ref/Alt1/Alt2
+func (this *SNV) SplitAlts() []*SNV {
+	alts := this.Alt()
+	if len(alts) <= 1 {
+		return []*SNV{this}
+	}
+	info := fmt.Sprintf("%s", this.Info())
+	origin := fmt.Sprintf("%s:%d:%s/%s", this.Chrom(), this.Pos, this.Ref(), strings.Join(alts, "/"))
+	snvs := make([]*SNV, len(alts))
+	for i, alt := range alts {
+		variant := this.Variant
+		variant.Alternate = []string{alt}
+		variant.Info_ = vcfgo.NewInfoByte([]byte(splitInfo(info, this.Header, i, len(alts))), this.Header)
+		variant.Info_.Set("MULTIALLELIC", origin)
+		if this.Samples != nil {
+			variant.Samples = make([]*vcfgo.SampleGenotype, len(this.Samples))
+			for j, sample := range this.Samples {
+				variant.Samples[j] = splitSample(sample, this.Header, i, len(alts))
+			}
+		}
+		snvs[i] = &SNV{Variant: variant}
+	}
+	return snvs
+}
+
+// AlleleValue 取多等位位点中第i个ALT对应的INFO值，Number=A取第i个值，Number=R取REF和第i个ALT的值
+func AlleleValue(val any, info *vcfgo.Info, i int, n int) any {
+	value := reflect.ValueOf(val)
+	if info == nil || n <= 1 || value.Kind() != reflect.Slice {
+		return val
+	}
+	if info.Number == "A" && value.Len() == n {
+		return value.Index(i).Interface()
+	}
+	if info.Number == "R" && value.Len() == n+1 {
+		return reflect.Append(reflect.MakeSlice(value.Type(), 0, 2), value.Index(0), value.Index(i+1)).Interface()
+	}
+	return val
+}
+
 type CNV struct {
 	vcfgo.Variant
 }
@@ -119,3 +240,21 @@ func (this *CNV) AnnoVariant() AnnoVariant {
 	chrom, start, end, ref, alt := this.Chrom(), int(this.Pos), int(this.End()), this.Ref(), this.Alt()[0]
 	return AnnoVariant{Chrom: chrom, Start: start, End: end, Ref: ref, Alt: alt}
 }
+
+// Position 用于tabix查询的基因组区间
+type Position struct {
+	Chromosome string
+	Begin      int
+	Stop       int
+}
+
+// NewPosition 根据1-based闭区间创建查询区间
+func NewPosition(chrom string, start, end int) Position {
+	return Position{Chromosome: chrom, Begin: Max(start, 1), Stop: Max(end, 1)}
+}
+
+func (this Position) Chrom() string { return this.Chromosome }
+
+func (this Position) Start() uint32 { return uint32(this.Begin - 1) }
+
+func (this Position) End() uint32 { return uint32(this.Stop) }
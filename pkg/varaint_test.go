@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brentp/vcfgo"
+)
+
+const testSplitVcf = `##fileformat=VCFv4.2
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##FORMAT=<ID=AF,Number=A,Type=Float,Description="Allele fractions">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled likelihoods">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+chr1	100	.	C	A,G	.	PASS	AC=1,2	GT:AD:AF:PL:DP	1/2:1,10,20:0.3,0.6:90,60,50,30,0,40:31	0|2:15,0,16:0,0.5:0,40,90,10,50,80:31	./.:.:.:.:.
+`
+
+func TestSplitAlts(t *testing.T) {
+	reader, err := vcfgo.NewReader(strings.NewReader(testSplitVcf), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variant := reader.Read()
+	if variant == nil {
+		t.Fatal(reader.Error())
+	}
+	tests := []struct {
+		alt     string
+		ac      string
+		samples []string
+		gts     [][]int
+	}{
+		{
+			"A", "AC=1",
+			[]string{"1/0:1,10:0.3:90,60,50:31", "0|0:15,0:0:0,40,90:31", "./.:.:.:.:."},
+			[][]int{{1, 0}, {0, 0}, {-1, -1}},
+		},
+		{
+			"G", "AC=2",
+			[]string{"0/1:1,20:0.6:90,30,40:31", "0|1:15,16:0.5:0,10,80:31", "./.:.:.:.:."},
+			[][]int{{0, 1}, {0, 1}, {-1, -1}},
+		},
+	}
+	snvs := (&SNV{Variant: *variant}).SplitAlts()
+	if len(snvs) != len(tests) {
+		t.Fatalf("got %d records, want %d", len(snvs), len(tests))
+	}
+	for i, test := range tests {
+		snv := snvs[i]
+		fields := strings.Split(snv.String(), "\t")
+		if snv.Alt()[0] != test.alt || !strings.HasPrefix(fields[7], test.ac+";") {
+			t.Errorf("%s: got %s %s, want %s %s", test.alt, snv.Alt()[0], fields[7], test.alt, test.ac)
+		}
+		if samples := strings.Join(fields[9:], "\t"); samples != strings.Join(test.samples, "\t") {
+			t.Errorf("%s: got %s, want %s", test.alt, samples, strings.Join(test.samples, "\t"))
+		}
+		for j, gt := range test.gts {
+			if got := snv.Samples[j].GT; len(got) != len(gt) || got[0] != gt[0] || got[1] != gt[1] {
+				t.Errorf("%s: sample %d got GT %v, want %v", test.alt, j, got, gt)
+			}
+		}
+	}
+	// 原始记录的样本不应被修改
+	if gt := variant.Samples[0].Fields["GT"]; gt != "1/2" {
+		t.Errorf("origin: got GT %s, want 1/2", gt)
+	}
+}